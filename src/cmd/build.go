@@ -0,0 +1,233 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	buildFlags struct {
+		file string
+		tag  string
+	}
+)
+
+var buildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a toolbox image from a Containerfile",
+	RunE:  build,
+}
+
+func init() {
+	flags := buildCmd.Flags()
+
+	flags.StringVarP(&buildFlags.file,
+		"file",
+		"f",
+		"",
+		"Use a different Containerfile than the one in the build context")
+
+	flags.StringVarP(&buildFlags.tag,
+		"tag",
+		"t",
+		"",
+		"Assign a name to the toolbox image")
+
+	buildCmd.SetHelpFunc(buildHelp)
+	rootCmd.AddCommand(buildCmd)
+}
+
+func build(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if buildFlags.tag == "" {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "missing option '--tag'\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	if len(args) > 1 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "too many arguments for \"build\"\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	buildContext := "."
+	if len(args) != 0 {
+		buildContext = args[0]
+	}
+
+	if err := buildImage(buildContext, buildFlags.file, buildFlags.tag); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created image: %s\n", buildFlags.tag)
+	fmt.Printf("Create a container with: %s create --image %s\n", executableBase, buildFlags.tag)
+
+	return nil
+}
+
+// buildImage builds an image marked as a toolbox image, and only tags it if
+// it can be used for toolbox containers. Otherwise, the image is removed,
+// unless it already had a name from an earlier build.
+func buildImage(buildContext, file, tag string) error {
+	workDir, err := ioutil.TempDir("", "toolbox-build")
+	if err != nil {
+		return errors.New("failed to create temporary directory")
+	}
+
+	defer os.RemoveAll(workDir)
+
+	iidFile := filepath.Join(workDir, "iid")
+
+	buildArgs := []string{"--iidfile", iidFile}
+
+	labels := make([]string, 0, len(toolboxLabels))
+	for label := range toolboxLabels {
+		labels = append(labels, label)
+	}
+
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		buildArgs = append(buildArgs, "--label", label+"="+toolboxLabels[label])
+	}
+
+	if file != "" {
+		buildArgs = append(buildArgs, "--file", file)
+	}
+
+	buildArgs = append(buildArgs, buildContext)
+
+	logrus.Debugf("Building image from %s", buildContext)
+
+	if err := engine.Build(buildArgs, os.Stdout, os.Stderr); err != nil {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "failed to build image from %s\n", buildContext)
+		fmt.Fprintf(&builder, "Use '%s --verbose ...' for further details.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	iidBytes, err := ioutil.ReadFile(iidFile)
+	if err != nil {
+		return fmt.Errorf("failed to read ID of image built from %s", buildContext)
+	}
+
+	imageID := strings.TrimSpace(string(iidBytes))
+	imageID = strings.TrimPrefix(imageID, "sha256:")
+
+	result, err := checkImage(imageID)
+	if err == nil && !result.Compatible {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "image built from %s can't be used for toolbox containers\n", buildContext)
+
+		for _, problem := range result.problems() {
+			fmt.Fprintf(&builder, "%s\n", capitalizeFirst(problem))
+		}
+
+		fmt.Fprintf(&builder, "Fix the Containerfile and try again.")
+
+		errMsg := builder.String()
+		err = errors.New(errMsg)
+	}
+
+	if err != nil {
+		removeUntaggedImage(imageID)
+		return err
+	}
+
+	for _, warning := range result.warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	logrus.Debugf("Tagging image %s as %s", imageID, tag)
+
+	if err := engine.Tag(imageID, tag); err != nil {
+		return fmt.Errorf("failed to tag image %s as %s", utils.ShortID(imageID), tag)
+	}
+
+	return nil
+}
+
+func buildHelp(cmd *cobra.Command, args []string) {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			fmt.Fprintf(os.Stderr, "Error: this is not a toolbox container\n")
+			return
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return
+		}
+
+		return
+	}
+
+	if err := showManual("toolbox-build"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+}
+
+// removeUntaggedImage removes an image, unless it has names. A build can
+// produce an image that already exists if all the layers are cached.
+func removeUntaggedImage(image string) {
+	info, err := engine.Inspect("image", image)
+	if err != nil {
+		logrus.Debugf("Inspecting image %s failed: %s", image, err)
+		return
+	}
+
+	if len(info.RepoTags) != 0 {
+		logrus.Debugf("Not removing image %s: it has names", image)
+		return
+	}
+
+	if err := engine.RemoveImage(image, false); err != nil {
+		logrus.Debugf("Removing image %s failed: %s", image, err)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildImage(t *testing.T) {
+	const imageID = "0123456789ab"
+
+	testCases := []struct {
+		name      string
+		output    string
+		repoTags  []string
+		errMsg    string
+		tagged    bool
+		removed   bool
+		buildArgs []string
+	}{
+		{
+			name:   "Compatible",
+			tagged: true,
+		},
+		{
+			name:   "Compatible, but missing optional binary",
+			output: "missing-binary updatedb\n",
+			tagged: true,
+		},
+		{
+			name:   "Incompatible",
+			output: "missing-binary capsh\nmissing-sudo-group\n",
+			errMsg: "image built from ./devel can't be used for toolbox containers\n" +
+				"Missing binary capsh\n" +
+				"Missing group sudo or wheel\n" +
+				"Fix the Containerfile and try again.",
+			removed: true,
+		},
+		{
+			name:     "Incompatible, but already named",
+			output:   "missing-profile.d\n",
+			repoTags: []string{"localhost/devel:latest"},
+			errMsg: "image built from ./devel can't be used for toolbox containers\n" +
+				"Missing /etc/profile.d support in /etc/profile\n" +
+				"Fix the Containerfile and try again.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, restore := useFakeEngine()
+			defer restore()
+			fake.inspectResults["image/"+imageID] = &podman.InspectResult{RepoTags: tc.repoTags}
+			fake.runOutput = func(args []string) string { return tc.output }
+
+			err := buildImage("./devel", "Containerfile.devel", "devel")
+
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+
+			builds := fake.callsTo("Build")
+			require.Len(t, builds, 1)
+			assert.Equal(t, []string{
+				"--label", "com.github.containers.toolbox=true",
+				"--label", "com.github.debarshiray.toolbox=true",
+				"--file", "Containerfile.devel",
+				"./devel",
+			}, builds[0][2:])
+
+			runs := fake.callsTo("Run")
+			require.Len(t, runs, 1)
+			assert.Contains(t, runs[0], imageID)
+
+			if tc.tagged {
+				assert.Equal(t, [][]string{{imageID, "devel"}}, fake.callsTo("Tag"))
+			} else {
+				assert.Empty(t, fake.callsTo("Tag"))
+			}
+
+			if tc.removed {
+				assert.Equal(t, [][]string{{imageID, "false"}}, fake.callsTo("RemoveImage"))
+			} else {
+				assert.Empty(t, fake.callsTo("RemoveImage"))
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	// commitCleanupScript removes the state that 'init-container' leaves
+	// behind in a toolbox container. Everything else that it does either
+	// lives outside the container, or is redone when the container starts.
+	// The sudoers file is only removed if 'init-container' wrote it,
+	// because the image might have its own, and the user is only removed
+	// if one is given, because it might not have been added by
+	// 'init-container' either.
+	commitCleanupScript = `set -e
+rm -f /run/.toolboxenv /usr/lib/rpm/macros.d/macros.toolbox
+if [ "$(head -n 1 /etc/sudoers.d/toolbox 2>/dev/null)" = "# Written by Toolbox" ]; then
+    rm -f /etc/sudoers.d/toolbox
+fi
+if [ -n "$1" ] && id "$1" >/dev/null 2>&1; then
+    userdel "$1"
+fi`
+)
+
+var commitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "Create a toolbox image from a toolbox container",
+	RunE:  commit,
+}
+
+func init() {
+	commitCmd.SetHelpFunc(commitHelp)
+	rootCmd.AddCommand(commitCmd)
+}
+
+func commit(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if len(args) < 2 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "missing argument for \"commit\"\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	if len(args) > 2 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "too many arguments for \"commit\"\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	container := args[0]
+	image := args[1]
+
+	if err := commitContainer(container, image); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created image: %s\n", image)
+	fmt.Printf("Create a container with: %s create --image %s\n", executableBase, image)
+
+	return nil
+}
+
+func commitContainer(container, image string) error {
+	logrus.Debugf("Inspecting container %s", container)
+
+	info, err := engine.Inspect("container", container)
+	if err != nil {
+		err := createErrorContainerNotFound(container)
+		return err
+	}
+
+	if !info.IsToolbox() {
+		return fmt.Errorf("%s is not a toolbox container", container)
+	}
+
+	s := spinner.New(spinner.CharSets[9], 500*time.Millisecond)
+
+	stdoutFd := os.Stdout.Fd()
+	stdoutFdInt := int(stdoutFd)
+	if logLevel := logrus.GetLevel(); logLevel < logrus.DebugLevel && term.IsTerminal(stdoutFdInt) {
+		s.Prefix = fmt.Sprintf("Committing container %s: ", container)
+		s.Writer = os.Stdout
+		s.Start()
+		defer s.Stop()
+	}
+
+	if err := commitToolboxContainer(container, info, image); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// commitToolboxContainer creates image from a toolbox container that was
+// already inspected. The container is first committed to a temporary image,
+// and the state left behind by 'init-container' is removed in a throwaway
+// container created from it, because the original container is likely to be
+// in use.
+func commitToolboxContainer(container string, info *podman.InspectResult, image string) error {
+	user := getInitContainerArguments(info.Config.Cmd)["--user"]
+	if user == "" {
+		user = currentUser.Username
+	}
+
+	baseImageCmd := []string{"/bin/sh"}
+	var baseImageEnv []string
+
+	logrus.Debugf("Inspecting image %s", info.Image)
+
+	if baseImageInfo, err := engine.Inspect("image", info.Image); err != nil {
+		logrus.Debugf("Inspecting image %s failed: %s", info.Image, err)
+	} else {
+		if len(baseImageInfo.Config.Cmd) != 0 {
+			baseImageCmd = baseImageInfo.Config.Cmd
+		}
+
+		baseImageEnv = baseImageInfo.Config.Env
+	}
+
+	// The user of a rootful toolbox container is root, and users that
+	// were already in the image were only modified by 'init-container'.
+	// Neither can be removed.
+	removedUser := user
+	if user == "root" || isUserInImage(info.Image, user) {
+		logrus.Debugf("Not removing user %s from container %s", user, container)
+		removedUser = ""
+	}
+
+	baseImageCmdJSON, err := json.Marshal(baseImageCmd)
+	if err != nil {
+		return fmt.Errorf("failed to encode command of image %s: %w", info.Image, err)
+	}
+
+	pid := os.Getpid()
+	temporaryImage := fmt.Sprintf("localhost/toolbox-commit-%d:latest", pid)
+	temporaryContainer := fmt.Sprintf("toolbox-commit-%d", pid)
+
+	logrus.Debugf("Committing container %s to temporary image %s", container, temporaryImage)
+
+	if err := engine.Commit([]string{container, temporaryImage}); err != nil {
+		return fmt.Errorf("failed to commit container %s", container)
+	}
+
+	defer func() {
+		if err := engine.RemoveImage(temporaryImage, false); err != nil {
+			logrus.Debugf("Removing image %s failed: %s", temporaryImage, err)
+		}
+	}()
+
+	logrus.Debugf("Removing runtime state of container %s in container %s", container, temporaryContainer)
+
+	runArgs := []string{
+		"--name", temporaryContainer,
+		"--network", "none",
+		"--user", "root:root",
+		temporaryImage,
+		"sh", "-c", commitCleanupScript, "sh", removedUser,
+	}
+
+	exitCode, err := engine.Run(runArgs, nil, nil, nil)
+
+	defer func() {
+		if err := engine.RemoveContainer(temporaryContainer, true); err != nil {
+			logrus.Debugf("Removing container %s failed: %s", temporaryContainer, err)
+		}
+	}()
+
+	if err != nil || exitCode != 0 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "failed to remove runtime state of container %s\n", container)
+		fmt.Fprintf(&builder, "Use '%s --verbose ...' for further details.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	commitArgs := []string{
+		"--change", "CMD " + string(baseImageCmdJSON),
+		"--change", "LABEL com.github.containers.toolbox=true",
+	}
+
+	for _, optionLabel := range createOptionLabels {
+		commitArgs = append(commitArgs, "--change", "LABEL "+optionLabel.label+"=")
+	}
+
+	for _, env := range getCreateEnvironment(info.Labels, baseImageEnv) {
+		commitArgs = append(commitArgs, "--change", "ENV "+env)
+	}
+
+	commitArgs = append(commitArgs, temporaryContainer, image)
+
+	logrus.Debugf("Committing container %s to image %s", temporaryContainer, image)
+
+	if err := engine.Commit(commitArgs); err != nil {
+		return fmt.Errorf("failed to commit container %s to image %s", container, image)
+	}
+
+	return nil
+}
+
+func commitHelp(cmd *cobra.Command, args []string) {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			fmt.Fprintf(os.Stderr, "Error: this is not a toolbox container\n")
+			return
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return
+		}
+
+		return
+	}
+
+	if err := showManual("toolbox-commit"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+}
+
+// getCreateEnvironment returns the environment variables that 'toolbox
+// create' set in a container, with the values that they have in its image, so
+// that they can be reset when committing it. Variables that aren't set in the
+// image are emptied, because an image's environment can't be unset.
+func getCreateEnvironment(labels map[string]string, imageEnv []string) []string {
+	names := []string{"TOOLBOX_PATH", "XDG_RUNTIME_DIR"}
+
+	createOptions := getCreateOptionsFromLabels(labels)
+	for i := 0; i+1 < len(createOptions); i += 2 {
+		if createOptions[i] != "--env" {
+			continue
+		}
+
+		name := createOptions[i+1]
+		if j := strings.IndexRune(name, '='); j != -1 {
+			name = name[:j]
+		}
+
+		names = append(names, name)
+	}
+
+	var envs []string
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		var value string
+		for _, imageEnvVar := range imageEnv {
+			if strings.HasPrefix(imageEnvVar, name+"=") {
+				value = strings.TrimPrefix(imageEnvVar, name+"=")
+			}
+		}
+
+		envs = append(envs, name+"="+strconv.Quote(value))
+	}
+
+	return envs
+}
+
+// isUserInImage checks if a user exists in an image by running id(1) in a
+// throwaway container created from it. If it can't be checked, the user is
+// assumed to exist, so that it doesn't get removed by mistake.
+func isUserInImage(image, user string) bool {
+	logrus.Debugf("Checking if user %s exists in image %s", user, image)
+
+	args := []string{
+		"--network", "none",
+		"--rm",
+		"--user", "root:root",
+		image,
+		"id", user,
+	}
+
+	exitCode, err := engine.Run(args, nil, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		logrus.Debugf("Checking if user %s exists in image %s failed: %s", user, image, err)
+		return true
+	}
+
+	return exitCode != 1
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitContainer(t *testing.T) {
+	pid := os.Getpid()
+	temporaryImage := fmt.Sprintf("localhost/toolbox-commit-%d:latest", pid)
+	temporaryContainer := fmt.Sprintf("toolbox-commit-%d", pid)
+
+	baseImageInfo := &podman.InspectResult{}
+	baseImageInfo.Config.Cmd = []string{"/bin/bash"}
+	baseImageInfo.Config.Env = []string{"PATH=/usr/bin", "LANG=C.UTF-8"}
+
+	commitArgs := []string{
+		"--change", `CMD ["/bin/bash"]`,
+		"--change", "LABEL com.github.containers.toolbox=true",
+		"--change", "LABEL com.github.containers.toolbox.devices=",
+		"--change", "LABEL com.github.containers.toolbox.env=",
+		"--change", "LABEL com.github.containers.toolbox.volumes=",
+		"--change", `ENV TOOLBOX_PATH=""`,
+		"--change", `ENV XDG_RUNTIME_DIR=""`,
+		"--change", `ENV LANG="C.UTF-8"`,
+		"--change", `ENV FOO=""`,
+		temporaryContainer, "devel-image",
+	}
+
+	testCases := []struct {
+		name        string
+		user        string
+		userInImage bool
+		exitCode    int
+		errMsg      string
+		idRuns      int
+		removedUser string
+		commits     [][]string
+	}{
+		{
+			name:        "Success",
+			user:        "jdoe",
+			idRuns:      1,
+			removedUser: "jdoe",
+			commits:     [][]string{{"devel", temporaryImage}, commitArgs},
+		},
+		{
+			name:        "User in image",
+			user:        "jdoe",
+			userInImage: true,
+			idRuns:      1,
+			commits:     [][]string{{"devel", temporaryImage}, commitArgs},
+		},
+		{
+			name:    "Rootful",
+			user:    "root",
+			commits: [][]string{{"devel", temporaryImage}, commitArgs},
+		},
+		{
+			name:        "Clean-up fails",
+			user:        "jdoe",
+			exitCode:    2,
+			idRuns:      1,
+			removedUser: "jdoe",
+			errMsg: "failed to remove runtime state of container devel\n" +
+				"Use '" + executableBase + " --verbose ...' for further details.",
+			commits: [][]string{{"devel", temporaryImage}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &podman.InspectResult{
+				Image: "abc",
+				Labels: map[string]string{
+					"com.github.containers.toolbox":     "true",
+					"com.github.containers.toolbox.env": `["LANG=en_US.UTF-8","FOO"]`,
+				},
+			}
+
+			info.Config.Cmd = []string{"toolbox", "--log-level", "debug", "init-container", "--user", tc.user}
+
+			fake, restore := useFakeEngine()
+			defer restore()
+			fake.inspectResults["container/devel"] = info
+			fake.inspectResults["image/abc"] = baseImageInfo
+			fake.run = func(args []string) int {
+				if args[len(args)-2] == "id" {
+					if tc.userInImage {
+						return 0
+					}
+
+					return 1
+				}
+
+				return tc.exitCode
+			}
+
+			err := commitContainer("devel", "devel-image")
+
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+
+			assert.Equal(t, tc.commits, fake.callsTo("Commit"))
+
+			runs := fake.callsTo("Run")
+			require.Len(t, runs, tc.idRuns+1)
+
+			if tc.idRuns != 0 {
+				assert.Equal(t, []string{"abc", "id", tc.user}, runs[0][len(runs[0])-3:])
+			}
+
+			cleanUp := runs[len(runs)-1]
+			assert.Equal(t, []string{"--name", temporaryContainer}, cleanUp[:2])
+			assert.Equal(t, []string{"sh", tc.removedUser}, cleanUp[len(cleanUp)-2:])
+
+			assert.Equal(t, [][]string{{temporaryContainer, "true"}}, fake.callsTo("RemoveContainer"))
+			assert.Equal(t, [][]string{{temporaryImage, "false"}}, fake.callsTo("RemoveImage"))
+		})
+	}
+}
+
+func TestCommitNotToolbox(t *testing.T) {
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.inspectResults["container/foo"] = &podman.InspectResult{}
+
+	err := commitContainer("foo", "bar")
+	assert.EqualError(t, err, "foo is not a toolbox container")
+	assert.Empty(t, fake.callsTo("Commit"))
+}
+
+func TestCommitArguments(t *testing.T) {
+	if utils.IsInsideContainer() {
+		t.Skip("commands are forwarded to the host inside containers")
+	}
+
+	fake, restore := useFakeEngine()
+	defer restore()
+
+	err := commit(commitCmd, []string{"devel"})
+	assert.EqualError(t, err, "missing argument for \"commit\"\nRun '"+executableBase+" --help' for usage.")
+
+	err = commit(commitCmd, []string{"devel", "devel-image", "extra"})
+	assert.EqualError(t, err, "too many arguments for \"commit\"\nRun '"+executableBase+" --help' for usage.")
+
+	assert.Empty(t, fake.callsTo("Commit"))
+}
@@ -17,20 +17,23 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/acobaugh/osrelease"
 	"github.com/briandowns/spinner"
 	"github.com/containers/toolbox/pkg/podman"
-	"github.com/containers/toolbox/pkg/shell"
 	"github.com/containers/toolbox/pkg/utils"
 	"github.com/godbus/dbus/v5"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"golang.org/x/term"
 )
 
@@ -38,14 +41,88 @@ const (
 	alpha    = `abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ`
 	num      = `0123456789`
 	alphanum = alpha + num
+
+	// createZypperListScript lists the packages that were explicitly
+	// installed with zypper(8), which marks them with 'i+' in the status
+	// column of its search results. Those installed as dependencies are
+	// marked with 'i'.
+	createZypperListScript = `packages=$(zypper --non-interactive --quiet search --installed-only --type package) || exit
+printf '%s\n' "$packages" | while IFS='|' read -r status name rest; do
+    case "$status" in
+        *i+*) echo $name ;;
+    esac
+done`
 )
 
+// createOptionLabels maps the labels recording the options given to 'toolbox
+// create' to the 'podman create' options that they were passed to.
+var createOptionLabels = []struct {
+	label  string
+	option string
+}{
+	{"com.github.containers.toolbox.devices", "--device"},
+	{"com.github.containers.toolbox.env", "--env"},
+	{"com.github.containers.toolbox.volumes", "--volume"},
+}
+
+type packageManager struct {
+	ids     []string
+	install []string
+	list    []string
+}
+
+type createOptions struct {
+	devices  []string
+	env      []string
+	packages []string
+	volumes  []string
+}
+
 var (
 	createFlags struct {
-		container string
-		distro    string
-		image     string
-		release   string
+		container    string
+		devices      []string
+		distro       string
+		env          []string
+		image        string
+		packagesFile string
+		release      string
+		volumes      []string
+	}
+
+	// createPackageManagers maps the IDs and ID_LIKEs from os-release(5) to
+	// the commands used to install packages, and to list those that were
+	// explicitly installed, one per line.
+	createPackageManagers = []packageManager{
+		{
+			ids:     []string{"fedora", "rhel", "centos"},
+			install: []string{"dnf", "--assumeyes", "install"},
+			list:    []string{"dnf", "--quiet", "repoquery", "--userinstalled", "--queryformat", "%{name}\n"},
+		},
+		{
+			ids: []string{"debian", "ubuntu"},
+			install: []string{
+				"sh", "-c", "apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install --yes \"$@\"", "sh",
+			},
+			list: []string{"apt-mark", "showmanual"},
+		},
+		{
+			ids:     []string{"arch"},
+			install: []string{"pacman", "--sync", "--refresh", "--needed", "--noconfirm"},
+			list:    []string{"pacman", "--query", "--quiet", "--explicit"},
+		},
+		{
+			ids:     []string{"opensuse", "suse", "sles"},
+			install: []string{"zypper", "--non-interactive", "install"},
+			list: []string{
+				"sh", "-c", createZypperListScript,
+			},
+		},
+		{
+			ids:     []string{"alpine"},
+			install: []string{"apk", "add"},
+			list:    []string{"cat", "/etc/apk/world"},
+		},
 	}
 
 	createToolboxShMounts = []struct {
@@ -72,24 +149,44 @@ func init() {
 		"",
 		"Assign a different name to the toolbox container")
 
+	flags.StringArrayVar(&createFlags.devices,
+		"device",
+		nil,
+		"Add a host device to the toolbox container")
+
 	flags.StringVarP(&createFlags.distro,
 		"distro",
 		"d",
 		"",
 		"Create a toolbox container for a different operating system distribution than the host")
 
+	flags.StringArrayVar(&createFlags.env,
+		"env",
+		nil,
+		"Set an environment variable in the toolbox container")
+
 	flags.StringVarP(&createFlags.image,
 		"image",
 		"i",
 		"",
 		"Change the name of the base image used to create the toolbox container")
 
+	flags.StringVar(&createFlags.packagesFile,
+		"packages-file",
+		"",
+		"Install the packages listed in a file in the toolbox container")
+
 	flags.StringVarP(&createFlags.release,
 		"release",
 		"r",
 		"",
 		"Create a toolbox container for a different operating system release than the host")
 
+	flags.StringArrayVar(&createFlags.volumes,
+		"volume",
+		nil,
+		"Bind mount a path or named volume into the toolbox container")
+
 	createCmd.SetHelpFunc(createHelp)
 	rootCmd.AddCommand(createCmd)
 }
@@ -138,17 +235,87 @@ func create(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	passthroughFlags := []struct {
+		flag     string
+		values   []string
+		validate func(string) error
+	}{
+		{"--device", createFlags.devices, utils.ValidateDevice},
+		{"--env", createFlags.env, utils.ValidateEnvironmentVariable},
+		{"--volume", createFlags.volumes, utils.ValidateVolume},
+	}
+
+	for _, passthroughFlag := range passthroughFlags {
+		for _, value := range passthroughFlag.values {
+			if err := passthroughFlag.validate(value); err != nil {
+				var builder strings.Builder
+				fmt.Fprintf(&builder, "invalid argument for '%s'\n", passthroughFlag.flag)
+				fmt.Fprintf(&builder, "%s\n", capitalizeFirst(err.Error()))
+				fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+				errMsg := builder.String()
+				return errors.New(errMsg)
+			}
+		}
+	}
+
+	distro := createFlags.distro
+	imageArg := createFlags.image
+	releaseArg := createFlags.release
+
+	var options createOptions
+
+	profile, err := utils.GetContainerProfile(container)
+	if err != nil {
+		return err
+	}
+
+	if profile != nil {
+		logrus.Debugf("Using the configuration of container %s from toolbox.conf", container)
+
+		if !cmd.Flag("distro").Changed && !cmd.Flag("image").Changed && !cmd.Flag("release").Changed {
+			distro = profile.Distro
+			imageArg = profile.Image
+			releaseArg = profile.Release
+		}
+
+		options = createOptions{
+			devices:  profile.Devices,
+			env:      profile.Environment,
+			packages: profile.Packages,
+			volumes:  profile.Volumes,
+		}
+	}
+
+	packagesFile := viper.GetString("general.packages-file")
+	if cmd.Flag("packages-file").Changed {
+		packagesFile = createFlags.packagesFile
+	}
+
+	if packagesFile != "" {
+		packages, err := utils.ReadPackagesFile(packagesFile)
+		if err != nil {
+			return err
+		}
+
+		options.packages = append(packages, options.packages...)
+	}
+
+	options.devices = append(options.devices, createFlags.devices...)
+	options.env = append(options.env, createFlags.env...)
+	options.volumes = append(options.volumes, createFlags.volumes...)
+
 	var release string
-	if createFlags.release != "" {
+	if releaseArg != "" {
 		var err error
-		release, err = utils.ParseRelease(createFlags.distro, createFlags.release)
+		release, err = utils.ParseRelease(distro, releaseArg)
 		if err != nil {
 			err := createErrorInvalidRelease()
 			return err
 		}
 	}
 
-	image, release, err := utils.ResolveImageName(createFlags.distro, createFlags.image, release)
+	image, release, err := utils.ResolveImageName(distro, imageArg, release)
 	if err != nil {
 		return err
 	}
@@ -158,14 +325,14 @@ func create(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if err := createContainer(container, image, release, true); err != nil {
+	if err := createContainer(container, image, release, options, true); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func createContainer(container, image, release string, showCommandToEnter bool) error {
+func createContainer(container, image, release string, options createOptions, showCommandToEnter bool) error {
 	if container == "" {
 		panic("container not specified")
 	}
@@ -182,14 +349,9 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 
 	logrus.Debugf("Checking if container %s already exists", container)
 
-	if exists, _ := podman.ContainerExists(container); exists {
-		var builder strings.Builder
-		fmt.Fprintf(&builder, "container %s already exists\n", container)
-		fmt.Fprintf(&builder, "Enter with: %s\n", enterCommand)
-		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
-
-		errMsg := builder.String()
-		return errors.New(errMsg)
+	if exists, _ := engine.ContainerExists(container); exists {
+		err := createErrorContainerExists(container)
+		return err
 	}
 
 	pulled, err := pullImage(image, release)
@@ -231,7 +393,7 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 
 	var devPtsMount []string
 
-	if podman.CheckVersion("2.1.0") {
+	if podman.CheckVersion(engine, "2.1.0") {
 		logrus.Debug("'podman create' supports '--mount type=devpts'")
 		devPtsMount = []string{"--mount", "type=devpts,destination=/dev/pts"}
 	}
@@ -240,7 +402,7 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 
 	var ulimitHost []string
 
-	if podman.CheckVersion("1.5.0") {
+	if podman.CheckVersion(engine, "1.5.0") {
 		logrus.Debug("'podman create' supports '--ulimit host'")
 		ulimitHost = []string{"--ulimit", "host"}
 	}
@@ -360,8 +522,6 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 		slashHomeLink = []string{"--home-link"}
 	}
 
-	logLevelString := podman.LogLevel.String()
-
 	userShell := os.Getenv("SHELL")
 	if userShell == "" {
 		return errors.New("failed to get the current user's default shell")
@@ -383,8 +543,6 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 	entryPoint = append(entryPoint, mntLink...)
 
 	createArgs := []string{
-		"--log-level", logLevelString,
-		"create",
 		"--dns", "none",
 		"--env", toolboxPathEnvArg,
 	}
@@ -397,6 +555,12 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 		"--label", "com.github.containers.toolbox=true",
 	}...)
 
+	optionLabels, err := getCreateOptionLabels(options)
+	if err != nil {
+		return err
+	}
+
+	createArgs = append(createArgs, optionLabels...)
 	createArgs = append(createArgs, devPtsMount...)
 
 	createArgs = append(createArgs, []string{
@@ -429,6 +593,18 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 	createArgs = append(createArgs, runMediaMount...)
 	createArgs = append(createArgs, toolboxShMount...)
 
+	for _, device := range options.devices {
+		createArgs = append(createArgs, "--device", device)
+	}
+
+	for _, env := range options.env {
+		createArgs = append(createArgs, "--env", env)
+	}
+
+	for _, volume := range options.volumes {
+		createArgs = append(createArgs, "--volume", volume)
+	}
+
 	createArgs = append(createArgs, []string{
 		imageFull,
 	}...)
@@ -437,6 +613,7 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 
 	logrus.Debugf("Creating container %s:", container)
 	logrus.Debug("podman")
+	logrus.Debug("create")
 	for _, arg := range createArgs {
 		logrus.Debugf("%s", arg)
 	}
@@ -452,10 +629,29 @@ func createContainer(container, image, release string, showCommandToEnter bool)
 		defer s.Stop()
 	}
 
-	if err := shell.Run("podman", nil, nil, nil, createArgs...); err != nil {
+	if err := engine.Create(createArgs); err != nil {
 		return fmt.Errorf("failed to create container %s", container)
 	}
 
+	if len(options.packages) != 0 {
+		s.Lock()
+		s.Prefix = fmt.Sprintf("Installing packages in container %s: ", container)
+		s.Unlock()
+
+		if err := installPackages(container, options.packages); err != nil {
+			// A half-provisioned container would get in the way of
+			// trying again.
+			logrus.Debugf("Removing container %s", container)
+
+			if err := engine.RemoveContainer(container, true); err != nil {
+				logrus.Debugf("Removing container %s failed: %s", container, err)
+				fmt.Fprintf(os.Stderr, "Error: failed to remove container %s\n", container)
+			}
+
+			return err
+		}
+	}
+
 	// The spinner must be stopped before showing the 'enter' hit below.
 	s.Stop()
 
@@ -488,6 +684,66 @@ func createHelp(cmd *cobra.Command, args []string) {
 	}
 }
 
+// getCreateOptionLabels returns the '--label' options that record the devices,
+// environment variables and volumes given to 'toolbox create', so that they
+// can be shown later on. Options that weren't used aren't recorded.
+func getCreateOptionLabels(options createOptions) ([]string, error) {
+	var labels []string
+
+	for _, optionLabel := range createOptionLabels {
+		var values []string
+
+		switch optionLabel.option {
+		case "--device":
+			values = options.devices
+		case "--env":
+			values = options.env
+		case "--volume":
+			values = options.volumes
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		valuesJSON, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode label %s: %w", optionLabel.label, err)
+		}
+
+		labels = append(labels, "--label", optionLabel.label+"="+string(valuesJSON))
+	}
+
+	return labels, nil
+}
+
+// getCreateOptionsFromLabels returns the '--device', '--env' and '--volume'
+// options recorded in the labels of a toolbox container by 'toolbox create'.
+func getCreateOptionsFromLabels(labels map[string]string) []string {
+	var options []string
+
+	for _, optionLabel := range createOptionLabels {
+		// 'toolbox commit' clears these labels, so that containers
+		// created from its images don't inherit them.
+		valuesJSON := labels[optionLabel.label]
+		if valuesJSON == "" {
+			continue
+		}
+
+		var values []string
+		if err := json.Unmarshal([]byte(valuesJSON), &values); err != nil {
+			logrus.Debugf("Failed to decode label %s: %s", optionLabel.label, err)
+			continue
+		}
+
+		for _, value := range values {
+			options = append(options, optionLabel.option, value)
+		}
+	}
+
+	return options
+}
+
 func getDBusSystemSocket() (string, error) {
 	logrus.Debug("Resolving path to the D-Bus system socket")
 
@@ -535,31 +791,30 @@ func getFullyQualifiedImageFromRepoTags(image string) (string, error) {
 	if utils.ImageReferenceHasDomain(image) {
 		imageFull = image
 	} else {
-		info, err := podman.Inspect("image", image)
+		info, err := engine.Inspect("image", image)
 		if err != nil {
 			return "", fmt.Errorf("failed to inspect image %s", image)
 		}
 
-		if info["RepoTags"] == nil {
+		repoTags := info.RepoTags
+		if repoTags == nil {
 			return "", fmt.Errorf("missing RepoTag for image %s", image)
 		}
 
-		repoTags := info["RepoTags"].([]interface{})
 		if len(repoTags) == 0 {
 			return "", fmt.Errorf("empty RepoTag for image %s", image)
 		}
 
 		for _, repoTag := range repoTags {
-			repoTagString := repoTag.(string)
-			tag := utils.ImageReferenceGetTag(repoTagString)
+			tag := utils.ImageReferenceGetTag(repoTag)
 			if tag != "latest" {
-				imageFull = repoTagString
+				imageFull = repoTag
 				break
 			}
 		}
 
 		if imageFull == "" {
-			imageFull = repoTags[0].(string)
+			imageFull = repoTags[0]
 		}
 	}
 
@@ -568,6 +823,46 @@ func getFullyQualifiedImageFromRepoTags(image string) (string, error) {
 	return imageFull, nil
 }
 
+func getPackageManager(container string) (*packageManager, error) {
+	logrus.Debugf("Detecting the package manager of container %s", container)
+
+	var stdout strings.Builder
+
+	args := []string{
+		"--user", "root",
+		container,
+		"sh", "-c", "cat /etc/os-release 2>/dev/null || cat /usr/lib/os-release",
+	}
+
+	exitCode, err := engine.Exec(args, nil, &stdout, nil)
+	if err != nil || exitCode != 0 {
+		return nil, fmt.Errorf("failed to read os-release in container %s", container)
+	}
+
+	osRelease, err := osrelease.ReadString(stdout.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse os-release in container %s", container)
+	}
+
+	ids := []string{osRelease["ID"]}
+	ids = append(ids, strings.Fields(osRelease["ID_LIKE"])...)
+
+	for _, id := range ids {
+		for i, manager := range createPackageManagers {
+			for _, managerID := range manager.ids {
+				if id != managerID {
+					continue
+				}
+
+				logrus.Debugf("Container %s uses the package manager for %s", container, id)
+				return &createPackageManagers[i], nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unknown package manager in container %s", container)
+}
+
 func getServiceSocket(serviceName string, unitName string) (string, error) {
 	logrus.Debugf("Resolving path to the %s socket", serviceName)
 
@@ -627,11 +922,60 @@ func getServiceSocket(serviceName string, unitName string) (string, error) {
 	return "", fmt.Errorf("failed to find a SOCK_STREAM socket for %s", unitName)
 }
 
+func installPackages(container string, packages []string) error {
+	logrus.Debugf("Installing packages in container %s", container)
+
+	if err := startContainer(container); err != nil {
+		return err
+	}
+
+	if err := waitForContainerInitialization(container); err != nil {
+		return err
+	}
+
+	manager, err := getPackageManager(container)
+	if err != nil {
+		return err
+	}
+
+	args := []string{
+		"--user", "root",
+		container,
+	}
+
+	args = append(args, manager.install...)
+	args = append(args, packages...)
+
+	logrus.Debugf("Installing packages in container %s:", container)
+	for _, arg := range args {
+		logrus.Debugf("%s", arg)
+	}
+
+	// The output of the package manager would garble the spinner, so it's
+	// only shown when debugging, which is when the spinner is off.
+	var stdout io.Writer
+	if logLevel := logrus.GetLevel(); logLevel >= logrus.DebugLevel {
+		stdout = os.Stdout
+	}
+
+	exitCode, err := engine.Exec(args, nil, stdout, nil)
+	if err != nil || exitCode != 0 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "failed to install packages in container %s\n", container)
+		fmt.Fprintf(&builder, "Use '%s --verbose ...' for further details.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	return nil
+}
+
 func pullImage(image, release string) (bool, error) {
 	if ok := utils.ImageReferenceCanBeID(image); ok {
 		logrus.Debugf("Looking for image %s", image)
 
-		if _, err := podman.ImageExists(image); err == nil {
+		if _, err := engine.ImageExists(image); err == nil {
 			return true, nil
 		}
 	}
@@ -642,7 +986,7 @@ func pullImage(image, release string) (bool, error) {
 		imageLocal := "localhost/" + image
 		logrus.Debugf("Looking for image %s", imageLocal)
 
-		if _, err := podman.ImageExists(imageLocal); err == nil {
+		if _, err := engine.ImageExists(imageLocal); err == nil {
 			return true, nil
 		}
 	}
@@ -661,7 +1005,7 @@ func pullImage(image, release string) (bool, error) {
 
 	logrus.Debugf("Looking for image %s", imageFull)
 
-	if _, err := podman.ImageExists(imageFull); err == nil {
+	if _, err := engine.ImageExists(imageFull); err == nil {
 		return true, nil
 	}
 
@@ -702,7 +1046,7 @@ func pullImage(image, release string) (bool, error) {
 		defer s.Stop()
 	}
 
-	if err := podman.Pull(imageFull); err != nil {
+	if err := engine.Pull(imageFull); err != nil {
 		var builder strings.Builder
 		fmt.Fprintf(&builder, "failed to pull image %s\n", imageFull)
 		fmt.Fprintf(&builder, "If it was a private image, log in with: podman login %s\n", domain)
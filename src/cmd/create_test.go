@@ -0,0 +1,286 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpCreateEnvironment makes createContainer independent of the host's
+// D-Bus, runtime directory and shell, until the returned function is called at
+// the end of a test.
+func setUpCreateEnvironment(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "toolbox-create")
+	require.NoError(t, err)
+
+	dbusSystemSocket := filepath.Join(dir, "system_bus_socket")
+	err = ioutil.WriteFile(dbusSystemSocket, nil, 0644)
+	if err != nil {
+		os.RemoveAll(dir)
+		require.NoError(t, err)
+	}
+
+	restoreEnv := []func(){
+		setEnv("DBUS_SYSTEM_BUS_ADDRESS", "unix:path="+dbusSystemSocket),
+		setEnv("SHELL", "/bin/zsh"),
+		setEnv("TOOLBOX_PATH", "/usr/bin/toolbox"),
+		setEnv("XDG_RUNTIME_DIR", dir),
+	}
+
+	restore := func() {
+		for i := len(restoreEnv) - 1; i >= 0; i-- {
+			restoreEnv[i]()
+		}
+
+		os.RemoveAll(dir)
+	}
+
+	return restore
+}
+
+func TestCreateContainer(t *testing.T) {
+	const image = "registry.fedoraproject.org/fedora-toolbox:35"
+
+	defer setUpCreateEnvironment(t)()
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.images = []podman.Image{{ID: "abc", Names: []string{image}}}
+
+	err := createContainer("foo", image, "35", createOptions{}, false)
+	require.NoError(t, err)
+
+	calls := fake.callsTo("Create")
+	require.Len(t, calls, 1)
+
+	createArgs := calls[0]
+	createArgsString := strings.Join(createArgs, " ")
+
+	assert.Contains(t, createArgsString, "--name foo ")
+	assert.Contains(t, createArgsString, "--label com.github.containers.toolbox=true ")
+	assert.Contains(t, createArgsString, "--env TOOLBOX_PATH=/usr/bin/toolbox ")
+	assert.Contains(t, createArgsString, "--volume /usr/bin/toolbox:/usr/bin/toolbox:ro ")
+	assert.Contains(t, createArgsString, "--mount type=devpts,destination=/dev/pts ")
+	assert.Contains(t, createArgsString, "--ulimit host ")
+
+	imageIndex := -1
+	for i, arg := range createArgs {
+		if arg == image {
+			imageIndex = i
+			break
+		}
+	}
+
+	require.NotEqual(t, -1, imageIndex, "image not found in %v", createArgs)
+
+	entryPoint := strings.Join(createArgs[imageIndex+1:], " ")
+	assert.True(t, strings.HasPrefix(entryPoint, "toolbox --log-level debug init-container "))
+	assert.Contains(t, entryPoint, "--shell /bin/zsh ")
+	assert.Contains(t, entryPoint, "--user "+currentUser.Username+" ")
+	assert.Equal(t, 0, len(fake.callsTo("Pull")))
+}
+
+func TestCreateContainerOldPodman(t *testing.T) {
+	const image = "registry.fedoraproject.org/fedora-toolbox:35"
+
+	defer setUpCreateEnvironment(t)()
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.images = []podman.Image{{ID: "abc", Names: []string{image}}}
+	fake.version = "1.4.4"
+
+	err := createContainer("foo", image, "35", createOptions{}, false)
+	require.NoError(t, err)
+
+	calls := fake.callsTo("Create")
+	require.Len(t, calls, 1)
+
+	createArgsString := strings.Join(calls[0], " ")
+	assert.NotContains(t, createArgsString, "type=devpts")
+	assert.NotContains(t, createArgsString, "--ulimit host")
+}
+
+func TestCreateContainerExists(t *testing.T) {
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.containers = []podman.Container{newFakeToolboxContainer("abc", "foo")}
+
+	err := createContainer("foo", "fedora-toolbox:35", "35", createOptions{}, false)
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "container foo already exists\n"))
+	assert.Equal(t, 0, len(fake.callsTo("Create")))
+}
+
+func TestCreateContainerOptions(t *testing.T) {
+	const image = "registry.fedoraproject.org/fedora-toolbox:35"
+
+	defer setUpCreateEnvironment(t)()
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.images = []podman.Image{{ID: "abc", Names: []string{image}}}
+
+	options := createOptions{
+		devices: []string{"/dev/kvm"},
+		env:     []string{"SDK_ROOT=/opt/sdk", "FOO"},
+		volumes: []string{"/opt/sdk:/opt/sdk:ro"},
+	}
+
+	err := createContainer("foo", image, "35", options, false)
+	require.NoError(t, err)
+
+	calls := fake.callsTo("Create")
+	require.Len(t, calls, 1)
+
+	createArgsString := strings.Join(calls[0], " ")
+	assert.Contains(t, createArgsString, " --device /dev/kvm ")
+	assert.Contains(t, createArgsString, " --env SDK_ROOT=/opt/sdk --env FOO ")
+	assert.Contains(t, createArgsString, " --volume /opt/sdk:/opt/sdk:ro "+image+" ")
+	assert.Contains(t, createArgsString, ` --label com.github.containers.toolbox.devices=["/dev/kvm"] `)
+	assert.Contains(t, createArgsString, ` --label com.github.containers.toolbox.env=["SDK_ROOT=/opt/sdk","FOO"] `)
+	assert.Contains(t, createArgsString, ` --label com.github.containers.toolbox.volumes=["/opt/sdk:/opt/sdk:ro"] `)
+}
+
+func TestGetCreateOptionsFromLabels(t *testing.T) {
+	labels := map[string]string{
+		"com.github.containers.toolbox":         "true",
+		"com.github.containers.toolbox.devices": "not JSON",
+		"com.github.containers.toolbox.env":     `["FOO=bar"]`,
+		"com.github.containers.toolbox.volumes": `["/opt/sdk:/opt/sdk:ro","cache:/var/cache"]`,
+	}
+
+	options := getCreateOptionsFromLabels(labels)
+	expected := []string{"--env", "FOO=bar", "--volume", "/opt/sdk:/opt/sdk:ro", "--volume", "cache:/var/cache"}
+	assert.Equal(t, expected, options)
+
+	assert.Empty(t, getCreateOptionsFromLabels(map[string]string{"com.github.containers.toolbox": "true"}))
+}
+
+func TestCreateContainerPackages(t *testing.T) {
+	const image = "registry.fedoraproject.org/fedora-toolbox:35"
+	const entryPointPID = 424242
+
+	defer setUpCreateEnvironment(t)()
+
+	toolboxRuntimeDirectory, err := utils.GetRuntimeDirectory(currentUser)
+	require.NoError(t, err)
+
+	initializedStamp := fmt.Sprintf("%s/container-initialized-%d", toolboxRuntimeDirectory, entryPointPID)
+	err = ioutil.WriteFile(initializedStamp, nil, 0644)
+	require.NoError(t, err)
+
+	defer os.Remove(initializedStamp)
+
+	testCases := []struct {
+		name      string
+		osRelease string
+		exitCode  int
+		install   string
+		errMsg    string
+	}{
+		{
+			name:      "Fedora",
+			osRelease: "NAME=Fedora\nID=fedora\nVERSION_ID=35\n",
+			install:   "dnf --assumeyes install gcc make",
+		},
+		{
+			name:      "Derivative",
+			osRelease: "NAME=\"Linux Mint\"\nID=linuxmint\nID_LIKE=\"ubuntu debian\"\n",
+			install:   "sh -c apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install --yes \"$@\" sh gcc make",
+		},
+		{
+			name:      "Unknown",
+			osRelease: "NAME=Foo\nID=foo\n",
+			errMsg:    "unknown package manager in container foo",
+		},
+		{
+			name:      "Installation fails",
+			osRelease: "NAME=Fedora\nID=fedora\nVERSION_ID=35\n",
+			exitCode:  1,
+			install:   "dnf --assumeyes install gcc make",
+			errMsg: "failed to install packages in container foo\n" +
+				"Use '" + executableBase + " --verbose ...' for further details.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, restore := useFakeEngine()
+			defer restore()
+			fake.images = []podman.Image{{ID: "abc", Names: []string{image}}}
+
+			info := &podman.InspectResult{}
+			info.Config.Cmd = []string{"toolbox", "--log-level", "debug", "init-container"}
+			info.State.Pid = entryPointPID
+			fake.inspectResults["container/foo"] = info
+
+			fake.execOutput = func(args []string) string {
+				if strings.Contains(execCommand(args, "foo"), "os-release") {
+					return tc.osRelease
+				}
+
+				return ""
+			}
+
+			fake.exec = func(args []string) int {
+				if strings.Contains(execCommand(args, "foo"), "os-release") {
+					return 0
+				}
+
+				return tc.exitCode
+			}
+
+			options := createOptions{packages: []string{"gcc", "make"}}
+			err := createContainer("foo", image, "35", options, false)
+
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+
+			assert.Equal(t, [][]string{{"foo"}}, fake.callsTo("Start"))
+
+			if tc.errMsg == "" {
+				assert.Empty(t, fake.callsTo("RemoveContainer"))
+			} else {
+				assert.Equal(t, [][]string{{"foo", "true"}}, fake.callsTo("RemoveContainer"))
+			}
+
+			calls := fake.callsTo("Exec")
+			if tc.install == "" {
+				assert.Len(t, calls, 1)
+				return
+			}
+
+			require.Len(t, calls, 2)
+			assert.Equal(t, []string{"--user", "root", "foo"}, calls[1][:3])
+			assert.Equal(t, tc.install, execCommand(calls[1], "foo"))
+		})
+	}
+}
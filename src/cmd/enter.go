@@ -31,6 +31,7 @@ var (
 		container string
 		distro    string
 		release   string
+		root      bool
 	}
 )
 
@@ -61,6 +62,11 @@ func init() {
 		"",
 		"Enter a toolbox container for a different operating system release than the host")
 
+	flags.BoolVar(&enterFlags.root,
+		"root",
+		false,
+		"Enter a toolbox container as root, with its capabilities")
+
 	enterCmd.SetHelpFunc(enterHelp)
 	rootCmd.AddCommand(enterCmd)
 }
@@ -133,6 +139,11 @@ func enter(cmd *cobra.Command, args []string) error {
 
 	command := []string{userShell, "-l"}
 
+	options := runOptions{session: true, tty: isStdioTerminal()}
+	if enterFlags.root {
+		options.user = "root"
+	}
+
 	hostID, err := utils.GetHostID()
 	if err != nil {
 		return fmt.Errorf("failed to get the host ID: %w", err)
@@ -145,7 +156,7 @@ func enter(cmd *cobra.Command, args []string) error {
 
 	var emitEscapeSequence bool
 
-	if hostID == "fedora" && (hostVariantID == "silverblue" || hostVariantID == "workstation") {
+	if options.tty && hostID == "fedora" && (hostVariantID == "silverblue" || hostVariantID == "workstation") {
 		emitEscapeSequence = true
 	}
 
@@ -154,6 +165,7 @@ func enter(cmd *cobra.Command, args []string) error {
 		image,
 		release,
 		command,
+		options,
 		emitEscapeSequence,
 		true,
 		false); err != nil {
@@ -0,0 +1,334 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// exportMetadata describes a toolbox container in an archive created by
+// 'toolbox export'. It's stored as exportMetadataFile next to the image of
+// the container, which is stored as exportImageFile.
+type exportMetadata struct {
+	Version   int               `json:"version"`
+	Container string            `json:"container"`
+	Image     string            `json:"image"`
+	BaseImage string            `json:"baseImage"`
+	Release   string            `json:"release"`
+	Labels    map[string]string `json:"labels"`
+	Mounts    []exportMount     `json:"mounts"`
+	Devices   []string          `json:"devices,omitempty"`
+	Env       []string          `json:"env,omitempty"`
+	Volumes   []string          `json:"volumes,omitempty"`
+}
+
+type exportMount struct {
+	Type        string   `json:"type"`
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Options     []string `json:"options,omitempty"`
+	RW          bool     `json:"rw"`
+}
+
+const (
+	exportImageFile    = "image.tar"
+	exportMetadataFile = "toolbox.json"
+	exportVersion      = 1
+)
+
+var (
+	exportFlags struct {
+		output string
+	}
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a toolbox container to a file",
+	RunE:  export,
+}
+
+func init() {
+	flags := exportCmd.Flags()
+
+	flags.StringVarP(&exportFlags.output,
+		"output",
+		"o",
+		"",
+		"Write the toolbox container to a file")
+
+	exportCmd.SetHelpFunc(exportHelp)
+	rootCmd.AddCommand(exportCmd)
+}
+
+func export(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if len(args) == 0 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "missing argument for \"export\"\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	if exportFlags.output == "" {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "missing option '--output'\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	container := args[0]
+
+	if err := exportContainer(container, exportFlags.output); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func exportContainer(container, output string) error {
+	logrus.Debugf("Inspecting container %s", container)
+
+	info, err := engine.Inspect("container", container)
+	if err != nil {
+		err := createErrorContainerNotFound(container)
+		return err
+	}
+
+	if !info.IsToolbox() {
+		return fmt.Errorf("%s is not a toolbox container", container)
+	}
+
+	metadata := getExportMetadata(container, info)
+
+	workDir, err := ioutil.TempDir("", "toolbox-export")
+	if err != nil {
+		return errors.New("failed to create temporary directory")
+	}
+
+	defer os.RemoveAll(workDir)
+
+	s := spinner.New(spinner.CharSets[9], 500*time.Millisecond)
+
+	stdoutFd := os.Stdout.Fd()
+	stdoutFdInt := int(stdoutFd)
+	if logLevel := logrus.GetLevel(); logLevel < logrus.DebugLevel && term.IsTerminal(stdoutFdInt) {
+		s.Prefix = fmt.Sprintf("Exporting container %s: ", container)
+		s.Writer = os.Stdout
+		s.Start()
+		defer s.Stop()
+	}
+
+	if err := commitToolboxContainer(container, info, metadata.Image); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := engine.RemoveImage(metadata.Image, false); err != nil {
+			logrus.Debugf("Removing image %s failed: %s", metadata.Image, err)
+		}
+	}()
+
+	imageArchive := filepath.Join(workDir, exportImageFile)
+
+	logrus.Debugf("Saving image %s to %s", metadata.Image, imageArchive)
+
+	if err := engine.Save(metadata.Image, imageArchive); err != nil {
+		return fmt.Errorf("failed to save image %s", metadata.Image)
+	}
+
+	if err := writeExportArchive(output, metadata, imageArchive); err != nil {
+		os.Remove(output)
+		return err
+	}
+
+	return nil
+}
+
+func exportHelp(cmd *cobra.Command, args []string) {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			fmt.Fprintf(os.Stderr, "Error: this is not a toolbox container\n")
+			return
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return
+		}
+
+		return
+	}
+
+	if err := showManual("toolbox-export"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+}
+
+// getExportMetadata collects what's needed to recreate a toolbox container
+// from its committed image on a different host. The devices, environment
+// variables and volumes are those recorded in the labels by 'toolbox create'.
+// The mounts are only informative, because the paths from the host that
+// toolbox containers need are different on every host.
+func getExportMetadata(container string, info *podman.InspectResult) exportMetadata {
+	baseImage := info.ImageName
+	if baseImage == "" {
+		baseImage = info.Image
+	}
+
+	release := utils.ImageReferenceGetTag(baseImage)
+	if release == "" {
+		release = "latest"
+	}
+
+	metadata := exportMetadata{
+		Version:   exportVersion,
+		Container: container,
+		Image:     fmt.Sprintf("localhost/toolbox-export-%s:latest", strings.ToLower(container)),
+		BaseImage: baseImage,
+		Release:   release,
+		Labels:    info.Labels,
+	}
+
+	for _, mount := range info.Mounts {
+		metadata.Mounts = append(metadata.Mounts, exportMount{
+			Type:        mount.Type,
+			Source:      mount.Source,
+			Destination: mount.Destination,
+			Options:     mount.Options,
+			RW:          mount.RW,
+		})
+	}
+
+	options := getCreateOptionsFromLabels(info.Labels)
+	for i := 0; i+1 < len(options); i += 2 {
+		switch options[i] {
+		case "--device":
+			metadata.Devices = append(metadata.Devices, options[i+1])
+		case "--env":
+			metadata.Env = append(metadata.Env, options[i+1])
+		case "--volume":
+			metadata.Volumes = append(metadata.Volumes, options[i+1])
+		}
+	}
+
+	return metadata
+}
+
+// writeExportArchive writes the metadata and the image of a toolbox container
+// to a tar archive.
+func writeExportArchive(output string, metadata exportMetadata, imageArchive string) error {
+	metadataBytes, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata of container %s: %w", metadata.Container, err)
+	}
+
+	image, err := os.Open(imageArchive)
+	if err != nil {
+		return fmt.Errorf("failed to open %s", imageArchive)
+	}
+
+	defer image.Close()
+
+	imageInfo, err := image.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s", imageArchive)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		logrus.Debugf("Creating %s failed: %s", output, err)
+		return fmt.Errorf("failed to create %s", output)
+	}
+
+	defer file.Close()
+
+	now := time.Now()
+	writer := tar.NewWriter(file)
+
+	metadataHeader := &tar.Header{
+		Name:    exportMetadataFile,
+		Mode:    0644,
+		Size:    int64(len(metadataBytes)),
+		ModTime: now,
+	}
+
+	if err := writer.WriteHeader(metadataHeader); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	if _, err := writer.Write(metadataBytes); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	imageHeader := &tar.Header{
+		Name:    exportImageFile,
+		Mode:    0644,
+		Size:    imageInfo.Size(),
+		ModTime: now,
+	}
+
+	if err := writer.WriteHeader(imageHeader); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	if _, err := io.Copy(writer, image); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	return nil
+}
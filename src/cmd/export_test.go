@@ -0,0 +1,228 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/tar"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	const exportedImage = "localhost/toolbox-export-devel:latest"
+	const importedImage = "localhost/toolbox-import-devel:0123456789ab"
+
+	defer setUpCreateEnvironment(t)()
+
+	dir, err := ioutil.TempDir("", "toolbox-export-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "devel.tar")
+
+	info := &podman.InspectResult{
+		ImageName: "registry.fedoraproject.org/fedora-toolbox:35",
+		Labels: map[string]string{
+			"com.github.containers.toolbox":         "true",
+			"com.github.containers.toolbox.volumes": `["/opt/sdk:/opt/sdk:ro"]`,
+			"com.github.containers.toolbox.env":     `["SDK_ROOT=/opt/sdk"]`,
+		},
+		Mounts: []podman.Mount{{Type: "bind", Source: "/opt/sdk", Destination: "/opt/sdk"}},
+	}
+
+	info.Config.Cmd = []string{"toolbox", "--log-level", "debug", "init-container", "--user", "jdoe"}
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.inspectResults["container/devel"] = info
+	fake.run = func(args []string) int {
+		if args[len(args)-2] == "id" {
+			return 1
+		}
+
+		return 0
+	}
+
+	err = exportContainer("devel", archive)
+	require.NoError(t, err)
+
+	pid := os.Getpid()
+	temporaryImage := fmt.Sprintf("localhost/toolbox-commit-%d:latest", pid)
+	temporaryContainer := fmt.Sprintf("toolbox-commit-%d", pid)
+
+	commits := fake.callsTo("Commit")
+	require.Len(t, commits, 2)
+	assert.Equal(t, []string{"devel", temporaryImage}, commits[0])
+	assert.Equal(t, []string{temporaryContainer, exportedImage}, commits[1][len(commits[1])-2:])
+
+	runs := fake.callsTo("Run")
+	require.Len(t, runs, 2)
+	cleanUp := runs[1]
+	assert.Equal(t, []string{"--name", temporaryContainer}, cleanUp[:2])
+	assert.Equal(t, []string{commitCleanupScript, "sh", "jdoe"}, cleanUp[len(cleanUp)-3:])
+
+	expectedRemoved := [][]string{{temporaryImage, "false"}, {exportedImage, "false"}}
+	assert.Equal(t, expectedRemoved, fake.callsTo("RemoveImage"))
+
+	workDir, err := ioutil.TempDir(dir, "import")
+	require.NoError(t, err)
+
+	metadata, imageArchive, err := readExportArchive(archive, workDir)
+	require.NoError(t, err)
+
+	expected := exportMetadata{
+		Version:   exportVersion,
+		Container: "devel",
+		Image:     exportedImage,
+		BaseImage: "registry.fedoraproject.org/fedora-toolbox:35",
+		Release:   "35",
+		Labels:    info.Labels,
+		Mounts:    []exportMount{{Type: "bind", Source: "/opt/sdk", Destination: "/opt/sdk"}},
+		Env:       []string{"SDK_ROOT=/opt/sdk"},
+		Volumes:   []string{"/opt/sdk:/opt/sdk:ro"},
+	}
+
+	assert.Equal(t, expected, metadata)
+
+	image, err := ioutil.ReadFile(imageArchive)
+	require.NoError(t, err)
+	assert.Equal(t, exportedImage, string(image))
+
+	fake, restore = useFakeEngine()
+	defer restore()
+	fake.images = []podman.Image{{ID: "0123456789abcdef", Names: []string{importedImage}}}
+	fake.inspectResults["image/"+exportedImage] = &podman.InspectResult{ID: "0123456789abcdef"}
+
+	err = importContainer(archive, "devel-copy")
+	require.NoError(t, err)
+
+	assert.Len(t, fake.callsTo("Load"), 1)
+	assert.Equal(t, [][]string{{exportedImage, importedImage}}, fake.callsTo("Tag"))
+	assert.Equal(t, [][]string{{exportedImage, "false"}}, fake.callsTo("RemoveImage"))
+
+	calls := fake.callsTo("Create")
+	require.Len(t, calls, 1)
+
+	createArgsString := strings.Join(calls[0], " ")
+	assert.Contains(t, createArgsString, "--name devel-copy ")
+	assert.Contains(t, createArgsString, " --env SDK_ROOT=/opt/sdk ")
+	assert.Contains(t, createArgsString, " --volume /opt/sdk:/opt/sdk:ro "+importedImage+" ")
+
+	fake, restore = useFakeEngine()
+	defer restore()
+
+	err = importContainer(archive, "devel-copy")
+	assert.EqualError(t, err, "invalid archive "+archive+": image "+exportedImage+" not loaded")
+	assert.Empty(t, fake.callsTo("Tag"))
+	assert.Empty(t, fake.callsTo("Create"))
+}
+
+func TestExportNotToolbox(t *testing.T) {
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.inspectResults["container/foo"] = &podman.InspectResult{}
+
+	err := exportContainer("foo", "/nonexistent/foo.tar")
+	assert.EqualError(t, err, "foo is not a toolbox container")
+	assert.Empty(t, fake.callsTo("Commit"))
+}
+
+func TestImportInvalidArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toolbox-import-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name   string
+		files  map[string]string
+		errMsg string
+	}{
+		{
+			name:   "Missing metadata",
+			files:  map[string]string{exportImageFile: "image"},
+			errMsg: "invalid archive %s: missing toolbox.json",
+		},
+		{
+			name:   "Missing image",
+			files:  map[string]string{exportMetadataFile: "{}"},
+			errMsg: "invalid archive %s: missing image.tar",
+		},
+		{
+			name: "Unsupported version",
+			files: map[string]string{
+				exportImageFile:    "image",
+				exportMetadataFile: `{"version": 2}`,
+			},
+			errMsg: "invalid archive %s: unsupported version 2",
+		},
+		{
+			name: "Image not in local storage",
+			files: map[string]string{
+				exportImageFile: "image",
+				exportMetadataFile: `{"version": 1, "container": "foo", ` +
+					`"image": "registry.fedoraproject.org/fedora-toolbox:35", "release": "35"}`,
+			},
+			errMsg: "invalid archive %s: invalid image registry.fedoraproject.org/fedora-toolbox:35",
+		},
+		{
+			name: "Invalid volume",
+			files: map[string]string{
+				exportImageFile: "image",
+				exportMetadataFile: `{"version": 1, "container": "foo", ` +
+					`"image": "localhost/foo:latest", "release": "latest", "volumes": ["/opt"]}`,
+			},
+			errMsg: "invalid archive %s: volume must be SOURCE:DESTINATION[:OPTIONS]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			archive := filepath.Join(dir, "archive.tar")
+			writeTestArchive(t, archive, tc.files)
+
+			_, _, err := readExportArchive(archive, dir)
+			assert.EqualError(t, err, strings.Replace(tc.errMsg, "%s", archive, 1))
+		})
+	}
+}
+
+func writeTestArchive(t *testing.T, archive string, files map[string]string) {
+	file, err := os.Create(archive)
+	require.NoError(t, err)
+	defer file.Close()
+
+	writer := tar.NewWriter(file)
+
+	for name, content := range files {
+		err := writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		require.NoError(t, err)
+
+		_, err = writer.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	err = writer.Close()
+	require.NoError(t, err)
+}
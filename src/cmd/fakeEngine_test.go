@@ -0,0 +1,424 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/containers/toolbox/pkg/podman"
+)
+
+// fakeCall records a single invocation of a method of fakeEngine.
+type fakeCall struct {
+	method string
+	args   []string
+}
+
+// fakeEngine is an in-process stand-in for Podman that records every call
+// made to it, so that the commands can be tested without a real container
+// engine.
+type fakeEngine struct {
+	calls []fakeCall
+
+	containers []podman.Container
+	images     []podman.Image
+
+	// inspectResults is keyed by "container/NAME" or "image/NAME".
+	inspectResults map[string]*podman.InspectResult
+
+	// exec decides the exit code of Exec. If it's nil, Exec succeeds.
+	exec func(args []string) int
+
+	// execOutput decides what Exec writes to its standard output, if
+	// anything.
+	execOutput func(args []string) string
+
+	// run decides the exit code of Run. If it's nil, Run succeeds.
+	run func(args []string) int
+
+	// runOutput decides what Run writes to its standard output, if
+	// anything.
+	runOutput func(args []string) string
+
+	// errors holds the errors to be returned by a method for a given
+	// argument, keyed by "METHOD/ARGUMENT".
+	errors map[string]error
+
+	version string
+}
+
+// useFakeEngine replaces the global engine with a fakeEngine, until the
+// returned function is called at the end of a test.
+func useFakeEngine() (*fakeEngine, func()) {
+	fake := &fakeEngine{
+		inspectResults: make(map[string]*podman.InspectResult),
+		errors:         make(map[string]error),
+		version:        "3.4.4",
+	}
+
+	engineOld := engine
+	engine = fake
+
+	restore := func() {
+		engine = engineOld
+	}
+
+	return fake, restore
+}
+
+// setEnv sets an environment variable, until the returned function is called
+// at the end of a test.
+func setEnv(key, value string) func() {
+	valueOld, found := os.LookupEnv(key)
+	os.Setenv(key, value)
+
+	restore := func() {
+		if found {
+			os.Setenv(key, valueOld)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+
+	return restore
+}
+
+func (f *fakeEngine) record(method string, args ...string) {
+	f.calls = append(f.calls, fakeCall{method, args})
+}
+
+// callsTo returns the arguments of all the calls made to a method.
+func (f *fakeEngine) callsTo(method string) [][]string {
+	var calls [][]string
+
+	for _, call := range f.calls {
+		if call.method == method {
+			calls = append(calls, call.args)
+		}
+	}
+
+	return calls
+}
+
+func (f *fakeEngine) err(method, arg string) error {
+	return f.errors[method+"/"+arg]
+}
+
+// Build writes the ID of the image to the file given with --iidfile.
+func (f *fakeEngine) Build(args []string, stdout, stderr io.Writer) error {
+	f.record("Build", args...)
+
+	if err := f.err("Build", ""); err != nil {
+		return err
+	}
+
+	if iidFile := getExecOption(args, "--iidfile"); iidFile != "" {
+		return ioutil.WriteFile(iidFile, []byte("sha256:0123456789ab"), 0644)
+	}
+
+	return nil
+}
+
+func (f *fakeEngine) Commit(args []string) error {
+	f.record("Commit", args...)
+	return f.err("Commit", "")
+}
+
+func (f *fakeEngine) ContainerExists(container string) (bool, error) {
+	f.record("ContainerExists", container)
+
+	for _, c := range f.containers {
+		if c.ID == container {
+			return true, nil
+		}
+
+		for _, name := range c.Names {
+			if name == container {
+				return true, nil
+			}
+		}
+	}
+
+	return false, fmt.Errorf("failed to find container %s", container)
+}
+
+func (f *fakeEngine) Create(args []string) error {
+	f.record("Create", args...)
+	return f.err("Create", "")
+}
+
+func (f *fakeEngine) Exec(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	f.record("Exec", args...)
+
+	if f.execOutput != nil && stdout != nil {
+		io.WriteString(stdout, f.execOutput(args))
+	}
+
+	if f.exec == nil {
+		return 0, nil
+	}
+
+	return f.exec(args), nil
+}
+
+func (f *fakeEngine) GetVersion() (string, error) {
+	f.record("GetVersion")
+	return f.version, nil
+}
+
+func (f *fakeEngine) ImageExists(image string) (bool, error) {
+	f.record("ImageExists", image)
+
+	for _, i := range f.images {
+		if i.ID == image {
+			return true, nil
+		}
+
+		for _, name := range i.Names {
+			if name == image {
+				return true, nil
+			}
+		}
+	}
+
+	return false, fmt.Errorf("failed to find image %s", image)
+}
+
+func (f *fakeEngine) Inspect(typearg string, target string) (*podman.InspectResult, error) {
+	f.record("Inspect", typearg, target)
+
+	info, found := f.inspectResults[typearg+"/"+target]
+	if !found {
+		return nil, fmt.Errorf("failed to find %s %s", typearg, target)
+	}
+
+	return info, nil
+}
+
+func (f *fakeEngine) InspectContainers(containers []string, size bool) ([]podman.InspectResult, error) {
+	f.record("InspectContainers", append([]string{fmt.Sprint(size)}, containers...)...)
+
+	var infos []podman.InspectResult
+
+	for _, container := range containers {
+		info, found := f.inspectResults["container/"+container]
+		if !found {
+			return nil, fmt.Errorf("failed to find container %s", container)
+		}
+
+		infos = append(infos, *info)
+	}
+
+	return infos, nil
+}
+
+func (f *fakeEngine) ListContainers() ([]podman.Container, error) {
+	f.record("ListContainers")
+	return f.containers, nil
+}
+
+func (f *fakeEngine) ListImages() ([]podman.Image, error) {
+	f.record("ListImages")
+	return f.images, nil
+}
+
+func (f *fakeEngine) Load(input string) error {
+	f.record("Load", input)
+	return f.err("Load", "")
+}
+
+func (f *fakeEngine) Pull(imageName string) error {
+	f.record("Pull", imageName)
+	return f.err("Pull", imageName)
+}
+
+func (f *fakeEngine) RemoveContainer(container string, forceDelete bool) error {
+	f.record("RemoveContainer", container, fmt.Sprint(forceDelete))
+	return f.err("RemoveContainer", container)
+}
+
+func (f *fakeEngine) RemoveImage(image string, forceDelete bool) error {
+	f.record("RemoveImage", image, fmt.Sprint(forceDelete))
+	return f.err("RemoveImage", image)
+}
+
+// Rename also renames the container in the list of containers, if it's there.
+func (f *fakeEngine) Rename(container, name string) error {
+	f.record("Rename", container, name)
+
+	if err := f.err("Rename", container); err != nil {
+		return err
+	}
+
+	for i := range f.containers {
+		for j := range f.containers[i].Names {
+			if f.containers[i].Names[j] == container {
+				f.containers[i].Names[j] = name
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeEngine) Run(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	f.record("Run", args...)
+
+	if f.runOutput != nil && stdout != nil {
+		io.WriteString(stdout, f.runOutput(args))
+	}
+
+	if f.run == nil {
+		return 0, nil
+	}
+
+	return f.run(args), nil
+}
+
+// Save writes the name of the image to the archive, instead of the image.
+func (f *fakeEngine) Save(image, output string) error {
+	f.record("Save", image, output)
+
+	if err := f.err("Save", image); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(output, []byte(image), 0644)
+}
+
+func (f *fakeEngine) Start(container string, stderr io.Writer) error {
+	f.record("Start", container)
+	return f.err("Start", container)
+}
+
+func (f *fakeEngine) Stop(container string) error {
+	f.record("Stop", container)
+	return f.err("Stop", container)
+}
+
+func (f *fakeEngine) Tag(image, name string) error {
+	f.record("Tag", image, name)
+	return f.err("Tag", image)
+}
+
+func (f *fakeEngine) SystemMigrate(ociRuntimeRequired string) error {
+	f.record("SystemMigrate", ociRuntimeRequired)
+	return f.err("SystemMigrate", ociRuntimeRequired)
+}
+
+// newFakeToolboxContainer returns a container as listed by the engine that is
+// marked as a toolbox container.
+func newFakeToolboxContainer(id, name string) podman.Container {
+	container := podman.Container{
+		ID:     id,
+		Names:  []string{name},
+		Status: "exited",
+		Labels: map[string]string{"com.github.containers.toolbox": "true"},
+	}
+
+	return container
+}
+
+// newFakeToolboxImage returns an image as listed by the engine that is marked
+// as a toolbox image.
+func newFakeToolboxImage(id, name string) podman.Image {
+	image := podman.Image{
+		ID:     id,
+		Names:  []string{name},
+		Labels: map[string]string{"com.github.containers.toolbox": "true"},
+	}
+
+	return image
+}
+
+// newFakeToolboxData returns a Fedora and a RHEL toolbox image, and a
+// container created from each, as listed by the engine. The Fedora container
+// is running, and was created with '--device /dev/kvm'. The RHEL image and
+// container are labelled with their vendor, and are newer and older than the
+// Fedora ones, respectively.
+func newFakeToolboxData() ([]podman.Image, []podman.Container) {
+	created := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	fedoraImage := newFakeToolboxImage("4f3c5c3b9d6b", "registry.fedoraproject.org/fedora-toolbox:34")
+	fedoraImage.Created = created
+	fedoraImage.Size = 300
+
+	rhelImage := newFakeToolboxImage("8a1b2c3d4e5f", "registry.access.redhat.com/ubi8/toolbox:8.4")
+	rhelImage.Created = created.Add(-time.Hour)
+	rhelImage.Labels["vendor"] = "Red Hat"
+	rhelImage.Size = 100
+
+	fedoraContainer := newFakeToolboxContainer("c0ffee", "fedora-toolbox-34")
+	fedoraContainer.Status = "running"
+	fedoraContainer.Image = fedoraImage.Names[0]
+	fedoraContainer.ImageID = fedoraImage.ID
+	fedoraContainer.Created = created
+	fedoraContainer.Labels["com.github.containers.toolbox.devices"] = `["/dev/kvm"]`
+
+	rhelContainer := newFakeToolboxContainer("decaf", "rhel-toolbox-8.4")
+	rhelContainer.Image = rhelImage.Names[0]
+	rhelContainer.ImageID = rhelImage.ID
+	rhelContainer.Created = created.Add(time.Hour)
+	rhelContainer.Labels["vendor"] = "Red Hat"
+
+	return []podman.Image{fedoraImage, rhelImage}, []podman.Container{fedoraContainer, rhelContainer}
+}
+
+// newFakeInspectResult returns the result of inspecting a container from
+// newFakeToolboxData, created by 'toolbox create' for the user 'user'.
+func newFakeInspectResult(container podman.Container) *podman.InspectResult {
+	info := &podman.InspectResult{
+		ID:        container.ID,
+		Name:      container.Names[0],
+		Image:     container.ImageID,
+		ImageName: container.Image,
+		Labels:    container.Labels,
+	}
+
+	info.Config.Cmd = []string{
+		"toolbox", "--log-level", "debug",
+		"init-container",
+		"--gid", "1000",
+		"--home", "/home/user",
+		"--shell", "/bin/bash",
+		"--uid", "1000",
+		"--user", "user",
+		"--monitor-host",
+	}
+
+	info.State.Status = container.Status
+	info.State.Running = container.Status == "running"
+
+	return info
+}
+
+// execCommand returns the command run by Exec, without the options for
+// 'podman exec' and the name of the container.
+func execCommand(args []string, container string) string {
+	for i, arg := range args {
+		if arg == container {
+			return strings.Join(args[i+1:], " ")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containers/toolbox/pkg/shell"
+	"github.com/sirupsen/logrus"
+)
+
+type hook struct {
+	name string
+	path string
+}
+
+const (
+	hookPostEnter = "post-enter"
+	hookPostInit  = "post-init"
+	hookPreEnter  = "pre-enter"
+)
+
+var (
+	// hooksSystemDirectory is the directory on the host with the hooks
+	// provided by the operating system distributor or the system
+	// administrator. Inside toolbox containers, it's found under
+	// /run/host.
+	hooksSystemDirectory = "/etc/toolbox/hooks.d"
+)
+
+// getHooks returns the executables for a phase from the system and user hook
+// directories, ordered by name. The user hook directory is looked up in
+// ~/.config, instead of $XDG_CONFIG_HOME, because only the home directory is
+// guaranteed to be at the same path inside and outside toolbox containers. A
+// user hook replaces a system hook with the same name.
+func getHooks(phase, systemDirectory, homeDirectory string) ([]hook, error) {
+	hookDirectories := []string{
+		filepath.Join(systemDirectory, phase),
+		filepath.Join(homeDirectory, ".config", "toolbox", "hooks.d", phase),
+	}
+
+	hooksByName := make(map[string]string)
+
+	for _, hookDirectory := range hookDirectories {
+		logrus.Debugf("Looking for %s hooks in %s", phase, hookDirectory)
+
+		entries, err := ioutil.ReadDir(hookDirectory)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			logrus.Debugf("Reading hook directory %s failed: %s", hookDirectory, err)
+			return nil, fmt.Errorf("failed to read hook directory %s", hookDirectory)
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+				continue
+			}
+
+			path := filepath.Join(hookDirectory, name)
+
+			fileInfo, err := os.Stat(path)
+			if err != nil {
+				logrus.Debugf("Skipping hook %s: %s", path, err)
+				continue
+			}
+
+			if !fileInfo.Mode().IsRegular() || fileInfo.Mode().Perm()&0111 == 0 {
+				logrus.Debugf("Skipping hook %s: not an executable file", path)
+				continue
+			}
+
+			hooksByName[name] = path
+		}
+	}
+
+	names := make([]string, 0, len(hooksByName))
+	for name := range hooksByName {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	hooks := make([]hook, 0, len(names))
+	for _, name := range names {
+		hooks = append(hooks, hook{name: name, path: hooksByName[name]})
+	}
+
+	return hooks, nil
+}
+
+func logHookOutput(phase, name, output string) {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		logrus.Infof("%s hook %s: %s", phase, name, line)
+	}
+}
+
+// runHooks runs the hooks for a phase of 'init-container' inside the toolbox
+// container.
+func runHooks(phase string) error {
+	hooks, err := getHooks(phase, "/run/host"+hooksSystemDirectory, initContainerFlags.home)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		logrus.Debugf("Running %s hook %s", phase, hook.path)
+
+		var output strings.Builder
+		exitCode, err := shell.RunWithExitCode(hook.path, nil, &output, &output)
+		logHookOutput(phase, hook.name, output.String())
+
+		if err != nil {
+			return fmt.Errorf("failed to run %s hook %s: %w", phase, hook.name, err)
+		}
+
+		if exitCode != 0 {
+			return fmt.Errorf("%s hook %s failed with exit code %d", phase, hook.name, exitCode)
+		}
+	}
+
+	return nil
+}
+
+// runHooksInContainer runs the hooks for a phase of 'toolbox enter' and
+// 'toolbox run' inside a toolbox container as the current user.
+func runHooksInContainer(container, phase string) error {
+	hooks, err := getHooks(phase, hooksSystemDirectory, currentUser.HomeDir)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		path := hook.path
+		if strings.HasPrefix(path, hooksSystemDirectory+"/") {
+			path = "/run/host" + path
+		}
+
+		logrus.Debugf("Running %s hook %s in container %s", phase, path, container)
+
+		args := []string{
+			"--user", currentUser.Username,
+			container,
+			path,
+		}
+
+		var output strings.Builder
+		exitCode, err := engine.Exec(args, nil, &output, &output)
+		logHookOutput(phase, hook.name, output.String())
+
+		if err != nil {
+			return fmt.Errorf("failed to run %s hook %s in container %s", phase, hook.name, container)
+		}
+
+		if exitCode != 0 {
+			return fmt.Errorf("%s hook %s failed in container %s with exit code %d",
+				phase,
+				hook.name,
+				container,
+				exitCode)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setUpHooks creates hook directories with the given files, and makes them
+// the system and user hook directories, until the returned function is called
+// at the end of a test. Files ending in '.sh' are executable.
+func setUpHooks(t *testing.T, systemHooks, userHooks []string) (string, string, func()) {
+	dir, err := ioutil.TempDir("", "toolbox-hooks")
+	require.NoError(t, err)
+
+	systemDirectory := filepath.Join(dir, "etc", "toolbox", "hooks.d")
+	homeDirectory := filepath.Join(dir, "home")
+	userDirectory := filepath.Join(homeDirectory, ".config", "toolbox", "hooks.d")
+
+	for _, hooks := range []struct {
+		directory string
+		files     []string
+	}{
+		{systemDirectory, systemHooks},
+		{userDirectory, userHooks},
+	} {
+		for _, file := range hooks.files {
+			path := filepath.Join(hooks.directory, file)
+			err := os.MkdirAll(filepath.Dir(path), 0755)
+			if err == nil {
+				var mode os.FileMode = 0644
+				if filepath.Ext(path) == ".sh" {
+					mode = 0755
+				}
+
+				err = ioutil.WriteFile(path, []byte("#!/bin/sh\n"), mode)
+			}
+
+			if err != nil {
+				os.RemoveAll(dir)
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	hooksSystemDirectoryOld := hooksSystemDirectory
+	hooksSystemDirectory = systemDirectory
+
+	currentUserOld := currentUser
+	currentUser = &user.User{Uid: "1000", Gid: "1000", Username: "user", HomeDir: homeDirectory}
+
+	restore := func() {
+		hooksSystemDirectory = hooksSystemDirectoryOld
+		currentUser = currentUserOld
+		os.RemoveAll(dir)
+	}
+
+	return systemDirectory, userDirectory, restore
+}
+
+func TestGetHooks(t *testing.T) {
+	systemDirectory, userDirectory, restoreHooks := setUpHooks(t,
+		[]string{"pre-enter/20-b.sh", "pre-enter/10-a.sh", "pre-enter/30-c.sh", "post-enter/10-a.sh"},
+		[]string{"pre-enter/15-d.sh", "pre-enter/30-c.sh", "pre-enter/40-e.txt", "pre-enter/.50-f.sh"})
+	defer restoreHooks()
+
+	hooks, err := getHooks(hookPreEnter, systemDirectory, currentUser.HomeDir)
+	require.NoError(t, err)
+
+	expected := []hook{
+		{"10-a.sh", filepath.Join(systemDirectory, "pre-enter", "10-a.sh")},
+		{"15-d.sh", filepath.Join(userDirectory, "pre-enter", "15-d.sh")},
+		{"20-b.sh", filepath.Join(systemDirectory, "pre-enter", "20-b.sh")},
+		{"30-c.sh", filepath.Join(userDirectory, "pre-enter", "30-c.sh")},
+	}
+
+	assert.Equal(t, expected, hooks)
+
+	hooks, err = getHooks(hookPostInit, systemDirectory, currentUser.HomeDir)
+	require.NoError(t, err)
+	assert.Empty(t, hooks)
+}
+
+func TestRunHooksInContainer(t *testing.T) {
+	const container = "fedora-toolbox-35"
+
+	systemDirectory, userDirectory, restoreHooks := setUpHooks(t,
+		[]string{"pre-enter/10-a.sh", "pre-enter/20-b.sh"},
+		[]string{"pre-enter/30-c.sh"})
+	defer restoreHooks()
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.exec = func(args []string) int {
+		if execCommand(args, container) == filepath.Join("/run/host", systemDirectory, "pre-enter", "20-b.sh") {
+			return 3
+		}
+
+		return 0
+	}
+
+	err := runHooksInContainer(container, hookPreEnter)
+	assert.EqualError(t, err, "pre-enter hook 20-b.sh failed in container "+container+" with exit code 3")
+
+	expected := [][]string{
+		{"--user", "user", container, filepath.Join("/run/host", systemDirectory, "pre-enter", "10-a.sh")},
+		{"--user", "user", container, filepath.Join("/run/host", systemDirectory, "pre-enter", "20-b.sh")},
+	}
+
+	assert.Equal(t, expected, fake.callsTo("Exec"))
+
+	fake, restore = useFakeEngine()
+	defer restore()
+
+	err = runHooksInContainer(container, hookPreEnter)
+	assert.NoError(t, err)
+
+	calls := fake.callsTo("Exec")
+	require.Len(t, calls, 3)
+	assert.Equal(t, filepath.Join(userDirectory, "pre-enter", "30-c.sh"), execCommand(calls[2], container))
+
+	err = runHooksInContainer(container, hookPostEnter)
+	assert.NoError(t, err)
+	assert.Len(t, fake.callsTo("Exec"), 3)
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	imageCheckFlags struct {
+		format string
+	}
+)
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage toolbox images",
+	RunE:  image,
+}
+
+var imageCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check if an image can be used for toolbox containers",
+	RunE:  imageCheck,
+}
+
+var imageUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Pull updates of toolbox images from their registries",
+	RunE:  imageUpdate,
+}
+
+func init() {
+	flags := imageCheckCmd.Flags()
+
+	flags.StringVar(&imageCheckFlags.format,
+		"format",
+		"",
+		"Change the output format to json")
+
+	imageCmd.SetHelpFunc(imageHelp)
+	imageCheckCmd.SetHelpFunc(imageHelp)
+	imageUpdateCmd.SetHelpFunc(imageHelp)
+
+	imageCmd.AddCommand(imageCheckCmd)
+	imageCmd.AddCommand(imageUpdateCmd)
+	rootCmd.AddCommand(imageCmd)
+}
+
+func image(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	var builder strings.Builder
+
+	if len(args) == 0 {
+		fmt.Fprintf(&builder, "missing command for \"image\"\n")
+	} else {
+		fmt.Fprintf(&builder, "unknown command \"%s\" for \"image\"\n", args[0])
+	}
+
+	fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+	errMsg := builder.String()
+	return errors.New(errMsg)
+}
+
+func imageCheck(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if imageCheckFlags.format != "" && imageCheckFlags.format != "json" {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "invalid argument for '--format'\n")
+		fmt.Fprintf(&builder, "Supported values are 'json'\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	if len(args) != 1 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "missing argument for \"image check\"\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	image := args[0]
+
+	if _, err := engine.ImageExists(image); err != nil {
+		return fmt.Errorf("image %s not found", image)
+	}
+
+	result, err := checkImage(image)
+	if err != nil {
+		return err
+	}
+
+	if err := imageCheckOutput(os.Stdout, result, imageCheckFlags.format); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// imageCheckOutput shows the result of checking an image, and fails if the
+// image can't be used for toolbox containers. The JSON output is written
+// even then, so that it can be used by scripts.
+func imageCheckOutput(writer io.Writer, result *imageCheckResult, format string) error {
+	if format == "json" {
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode result of checking image %s: %w", result.Image, err)
+		}
+
+		fmt.Fprintf(writer, "%s\n", resultJSON)
+
+		if !result.Compatible {
+			return fmt.Errorf("image %s can't be used for toolbox containers", result.Image)
+		}
+
+		return nil
+	}
+
+	for _, warning := range result.warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	if !result.Compatible {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "image %s can't be used for toolbox containers\n", result.Image)
+
+		problems := result.problems()
+		for i, problem := range problems {
+			fmt.Fprintf(&builder, "%s", capitalizeFirst(problem))
+			if i != len(problems)-1 {
+				fmt.Fprintf(&builder, "\n")
+			}
+		}
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	fmt.Fprintf(writer, "Image %s can be used for toolbox containers\n", result.Image)
+	return nil
+}
+
+func imageUpdate(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	images, err := getImages()
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 0 {
+		images, err = selectImages(images, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := updateImages(images); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func imageHelp(cmd *cobra.Command, args []string) {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			fmt.Fprintf(os.Stderr, "Error: this is not a toolbox container\n")
+			return
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return
+		}
+
+		return
+	}
+
+	if err := showManual("toolbox-image"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+}
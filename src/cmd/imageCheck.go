@@ -0,0 +1,197 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// imageCheckResult describes what a toolbox image lacks to be used for
+// toolbox containers.
+type imageCheckResult struct {
+	Image                   string   `json:"image"`
+	Compatible              bool     `json:"compatible"`
+	MissingBinaries         []string `json:"missingBinaries"`
+	MissingOptionalBinaries []string `json:"missingOptionalBinaries"`
+	MissingSudoGroup        bool     `json:"missingSudoGroup"`
+	MissingProfileD         bool     `json:"missingProfileD"`
+}
+
+var (
+	// imageCheckBinaries are the binaries used by 'init-container' to set
+	// up toolbox containers, and by 'enter' and 'run' to run commands in
+	// them.
+	imageCheckBinaries = []string{
+		"capsh",
+		"mount",
+		"passwd",
+		"sudo",
+		"useradd",
+		"usermod",
+	}
+
+	// imageCheckOptionalBinaries are the binaries used by 'init-container'
+	// whose absence it tolerates. Missing ones are only warned about.
+	imageCheckOptionalBinaries = []string{
+		"updatedb",
+	}
+)
+
+const (
+	// imageCheckScript reports one problem per line. It only relies on
+	// sh(1), because there's no telling what else an image has.
+	imageCheckScript = `for binary in "$@"; do
+    command -v "$binary" >/dev/null 2>&1 || echo "missing-binary $binary"
+done
+
+sudo_group=
+while IFS=: read -r name rest; do
+    case "$name" in
+        sudo|wheel) sudo_group="$name" ;;
+    esac
+done </etc/group
+[ -n "$sudo_group" ] || echo "missing-sudo-group"
+
+profile_d=
+if [ -d /etc/profile.d ] && [ -r /etc/profile ]; then
+    while read -r line; do
+        case "$line" in
+            *profile.d*) profile_d=1 ;;
+        esac
+    done </etc/profile
+fi
+[ -n "$profile_d" ] || echo "missing-profile.d"
+`
+)
+
+// checkImage checks if an image can be used for toolbox containers by
+// running imageCheckScript in a throwaway container created from it.
+func checkImage(image string) (*imageCheckResult, error) {
+	logrus.Debugf("Checking image %s", image)
+
+	args := []string{
+		"--network", "none",
+		"--rm",
+		"--user", "root:root",
+		image,
+		"sh", "-c", imageCheckScript, "sh",
+	}
+
+	args = append(args, imageCheckBinaries...)
+	args = append(args, imageCheckOptionalBinaries...)
+
+	var stdout strings.Builder
+
+	exitCode, err := engine.Run(args, nil, &stdout, nil)
+	if err != nil {
+		logrus.Debugf("Checking image %s failed: %s", image, err)
+		return nil, fmt.Errorf("failed to check image %s", image)
+	}
+
+	result := &imageCheckResult{
+		Image:                   image,
+		MissingBinaries:         []string{},
+		MissingOptionalBinaries: []string{},
+	}
+
+	if exitCode == 127 {
+		// sh(1) itself is missing, so nothing else can be checked.
+		result.MissingBinaries = append(result.MissingBinaries, "sh")
+		return result, nil
+	}
+
+	if exitCode != 0 {
+		logrus.Debugf("Checking image %s failed: exit code %d", image, exitCode)
+		return nil, fmt.Errorf("failed to check image %s", image)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(stdout.String()))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "missing-binary":
+			if len(fields) != 2 {
+				break
+			}
+
+			if isImageCheckOptionalBinary(fields[1]) {
+				result.MissingOptionalBinaries = append(result.MissingOptionalBinaries, fields[1])
+			} else {
+				result.MissingBinaries = append(result.MissingBinaries, fields[1])
+			}
+		case "missing-profile.d":
+			result.MissingProfileD = true
+		case "missing-sudo-group":
+			result.MissingSudoGroup = true
+		default:
+			logrus.Debugf("Checking image %s: ignoring unexpected output: %s", image, scanner.Text())
+		}
+	}
+
+	result.Compatible = len(result.MissingBinaries) == 0 && !result.MissingProfileD && !result.MissingSudoGroup
+	return result, nil
+}
+
+// problems returns a human-readable description of everything that the image
+// lacks.
+func (result *imageCheckResult) problems() []string {
+	var problems []string
+
+	for _, binary := range result.MissingBinaries {
+		problems = append(problems, fmt.Sprintf("missing binary %s", binary))
+	}
+
+	if result.MissingSudoGroup {
+		problems = append(problems, "missing group sudo or wheel")
+	}
+
+	if result.MissingProfileD {
+		problems = append(problems, "missing /etc/profile.d support in /etc/profile")
+	}
+
+	return problems
+}
+
+// warnings returns a human-readable description of everything that the image
+// lacks, but that doesn't stop it from being used for toolbox containers.
+func (result *imageCheckResult) warnings() []string {
+	var warnings []string
+
+	for _, binary := range result.MissingOptionalBinaries {
+		warnings = append(warnings, fmt.Sprintf("missing optional binary %s", binary))
+	}
+
+	return warnings
+}
+
+func isImageCheckOptionalBinary(binary string) bool {
+	for _, optionalBinary := range imageCheckOptionalBinaries {
+		if binary == optionalBinary {
+			return true
+		}
+	}
+
+	return false
+}
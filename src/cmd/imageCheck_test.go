@@ -0,0 +1,103 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckImage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		exitCode int
+		result   *imageCheckResult
+		errMsg   string
+	}{
+		{
+			name: "Compatible",
+			result: &imageCheckResult{
+				Image:                   "foo",
+				Compatible:              true,
+				MissingBinaries:         []string{},
+				MissingOptionalBinaries: []string{},
+			},
+		},
+		{
+			name:   "Missing optional binary",
+			output: "missing-binary updatedb\n",
+			result: &imageCheckResult{
+				Image:                   "foo",
+				Compatible:              true,
+				MissingBinaries:         []string{},
+				MissingOptionalBinaries: []string{"updatedb"},
+			},
+		},
+		{
+			name: "Missing everything",
+			output: "missing-binary capsh\nmissing-binary sudo\nmissing-binary updatedb\n" +
+				"missing-sudo-group\nmissing-profile.d\n",
+			result: &imageCheckResult{
+				Image:                   "foo",
+				MissingBinaries:         []string{"capsh", "sudo"},
+				MissingOptionalBinaries: []string{"updatedb"},
+				MissingSudoGroup:        true,
+				MissingProfileD:         true,
+			},
+		},
+		{
+			name:     "Missing sh",
+			exitCode: 127,
+			result: &imageCheckResult{
+				Image:                   "foo",
+				MissingBinaries:         []string{"sh"},
+				MissingOptionalBinaries: []string{},
+			},
+		},
+		{
+			name:     "Podman fails",
+			exitCode: 125,
+			errMsg:   "failed to check image foo",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, restore := useFakeEngine()
+			defer restore()
+			fake.run = func(args []string) int { return tc.exitCode }
+			fake.runOutput = func(args []string) string { return tc.output }
+
+			result, err := checkImage("foo")
+
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+
+			assert.Equal(t, tc.result, result)
+
+			runs := fake.callsTo("Run")
+			require.Len(t, runs, 1)
+			assert.Equal(t, []string{"--network", "none", "--rm", "--user", "root:root", "foo", "sh"}, runs[0][:7])
+		})
+	}
+}
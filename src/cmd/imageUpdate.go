@@ -0,0 +1,171 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containers/toolbox/pkg/registry"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// registryClient looks up the digests of images in their registries.
+	// Tests replace it with one that talks to a local stand-in.
+	registryClient = registry.NewClient(nil)
+)
+
+// getImageUpdate checks if the registry of a toolbox image has a different
+// manifest for it than the one that it was pulled as. The name that was
+// checked is returned, and is empty if the image has no name that is in a
+// registry, like those that were built locally.
+func getImageUpdate(image toolboxImage) (string, bool, error) {
+	for _, name := range image.Names {
+		domain := utils.ImageReferenceGetDomain(name)
+		if domain == "" || domain == "localhost" {
+			continue
+		}
+
+		logrus.Debugf("Checking if image %s is outdated", name)
+
+		digest, err := registryClient.GetDigest(name)
+		if err != nil {
+			return name, false, err
+		}
+
+		if digest == image.Digest {
+			return name, false, nil
+		}
+
+		for _, repoDigest := range image.RepoDigests {
+			if strings.HasSuffix(repoDigest, "@"+digest) {
+				return name, false, nil
+			}
+		}
+
+		logrus.Debugf("Image %s is outdated: registry has %s", name, digest)
+		return name, true, nil
+	}
+
+	return "", false, nil
+}
+
+// getOutdatedImages returns the images that have updates in their
+// registries. Images that couldn't be checked are reported and skipped.
+func getOutdatedImages(images []toolboxImage) []toolboxImage {
+	var outdated []toolboxImage
+
+	for _, image := range images {
+		_, isOutdated, err := getImageUpdate(image)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			continue
+		}
+
+		if isOutdated {
+			outdated = append(outdated, image)
+		}
+	}
+
+	return outdated
+}
+
+// selectImages returns the images that were asked for on the command line,
+// by ID or by name, including the short names matched by 'toolbox list
+// --filter name=...'.
+func selectImages(images []toolboxImage, args []string) ([]toolboxImage, error) {
+	var selected []toolboxImage
+	selectedIDs := make(map[string]bool)
+
+	for _, arg := range args {
+		var found bool
+
+		for _, image := range images {
+			matches := strings.HasPrefix(image.ID, arg)
+			for _, name := range image.Names {
+				if matchImageName(name, arg) {
+					matches = true
+				}
+			}
+
+			if !matches {
+				continue
+			}
+
+			found = true
+
+			if !selectedIDs[image.ID] {
+				selected = append(selected, image)
+				selectedIDs[image.ID] = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("toolbox image %s not found", arg)
+		}
+	}
+
+	return selected, nil
+}
+
+// updateImages pulls the updates of images from their registries. Images
+// without updates, or that can't be updated, are left alone.
+func updateImages(images []toolboxImage) error {
+	var failed bool
+
+	for _, image := range images {
+		name, isOutdated, err := getImageUpdate(image)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			failed = true
+			continue
+		}
+
+		if name == "" {
+			name = image.Name()
+			if name == "" {
+				name = image.ID
+			}
+
+			fmt.Printf("Image %s can't be updated: it isn't from a registry\n", name)
+			continue
+		}
+
+		if !isOutdated {
+			fmt.Printf("Image %s is up to date\n", name)
+			continue
+		}
+
+		if err := pullImageUpdate(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			failed = true
+			continue
+		}
+
+		fmt.Printf("Updated image %s\n", name)
+	}
+
+	if failed {
+		return errors.New("failed to update some images")
+	}
+
+	return nil
+}
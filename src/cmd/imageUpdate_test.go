@@ -0,0 +1,178 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/containers/toolbox/pkg/registry"
+	"github.com/containers/toolbox/pkg/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	imageUpdateTestManifestOld = `{"schemaVersion": 2, "config": "old"}`
+	imageUpdateTestManifestNew = `{"schemaVersion": 2, "config": "new"}`
+)
+
+// useRegistryStandIn replaces the registry client with one that talks to a
+// local registry, until the returned function is called at the end of a test.
+func useRegistryStandIn() (*registrytest.Server, func()) {
+	server := registrytest.NewServer()
+
+	registryClientOld := registryClient
+	registryClient = registry.NewClient(server.Client())
+
+	restore := func() {
+		registryClient = registryClientOld
+		server.Close()
+	}
+
+	return server, restore
+}
+
+// newImageUpdateTestData returns an up to date image, an outdated one, one
+// that was built locally, and one that isn't in the registry anymore.
+func newImageUpdateTestData(server *registrytest.Server) []toolboxImage {
+	server.SetManifest("fedora-toolbox", "34", imageUpdateTestManifestOld)
+	server.SetManifest("fedora-toolbox", "35", imageUpdateTestManifestNew)
+
+	digestOld := registrytest.Digest(imageUpdateTestManifestOld)
+
+	upToDate := newFakeToolboxImage("aaa", server.Domain()+"/fedora-toolbox:34")
+	upToDate.RepoDigests = []string{server.Domain() + "/fedora-toolbox@" + digestOld}
+
+	outdated := newFakeToolboxImage("bbb", server.Domain()+"/fedora-toolbox:35")
+	outdated.Digest = digestOld
+
+	local := newFakeToolboxImage("ccc", "localhost/custom:latest")
+	removed := newFakeToolboxImage("ddd", server.Domain()+"/fedora-toolbox:33")
+
+	return []toolboxImage{
+		newToolboxImage(upToDate),
+		newToolboxImage(outdated),
+		newToolboxImage(local),
+		newToolboxImage(removed),
+	}
+}
+
+func TestGetImageUpdate(t *testing.T) {
+	server, restoreRegistry := useRegistryStandIn()
+	defer restoreRegistry()
+	images := newImageUpdateTestData(server)
+
+	testCases := []struct {
+		name     string
+		image    toolboxImage
+		checked  string
+		outdated bool
+		errMsg   string
+	}{
+		{
+			name:    "Up to date",
+			image:   images[0],
+			checked: server.Domain() + "/fedora-toolbox:34",
+		},
+		{
+			name:     "Outdated",
+			image:    images[1],
+			checked:  server.Domain() + "/fedora-toolbox:35",
+			outdated: true,
+		},
+		{
+			name:  "Built locally",
+			image: images[2],
+		},
+		{
+			name:    "Not in registry",
+			image:   images[3],
+			checked: server.Domain() + "/fedora-toolbox:33",
+			errMsg: "image " + server.Domain() + "/fedora-toolbox:33 not found in registry " +
+				server.Domain(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checked, outdated, err := getImageUpdate(tc.image)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.checked, checked)
+			assert.Equal(t, tc.outdated, outdated)
+		})
+	}
+}
+
+func TestGetOutdatedImages(t *testing.T) {
+	server, restoreRegistry := useRegistryStandIn()
+	defer restoreRegistry()
+	images := newImageUpdateTestData(server)
+
+	outdated := getOutdatedImages(images)
+	require.Len(t, outdated, 1)
+	assert.Equal(t, "bbb", outdated[0].ID)
+
+	containers := []toolboxContainer{
+		{ID: "1", Names: []string{"fedora-toolbox-34"}, ImageID: "aaa"},
+		{ID: "2", Names: []string{"fedora-toolbox-35"}, ImageID: "bbb"},
+	}
+
+	containers = filterContainersByImages(containers, outdated)
+	require.Len(t, containers, 1)
+	assert.Equal(t, "2", containers[0].ID)
+}
+
+func TestUpdateImages(t *testing.T) {
+	server, restoreRegistry := useRegistryStandIn()
+	defer restoreRegistry()
+	images := newImageUpdateTestData(server)
+
+	fake, restore := useFakeEngine()
+	defer restore()
+
+	err := updateImages(images[:3])
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{server.Domain() + "/fedora-toolbox:35"}}, fake.callsTo("Pull"))
+
+	err = updateImages(images)
+	assert.EqualError(t, err, "failed to update some images")
+}
+
+func TestSelectImages(t *testing.T) {
+	server, restoreRegistry := useRegistryStandIn()
+	defer restoreRegistry()
+	images := newImageUpdateTestData(server)
+
+	selected, err := selectImages(images, []string{"fedora-toolbox:35", "ccc", server.Domain() + "/fedora-toolbox:35"})
+	require.NoError(t, err)
+
+	var ids []string
+	for _, image := range selected {
+		ids = append(ids, image.ID)
+	}
+
+	assert.Equal(t, []string{"bbb", "ccc"}, ids)
+
+	_, err = selectImages(images, []string{"ubuntu-toolbox:22.04"})
+	assert.EqualError(t, err, "toolbox image ubuntu-toolbox:22.04 not found")
+}
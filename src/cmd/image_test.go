@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageCheckOutput(t *testing.T) {
+	compatible := &imageCheckResult{
+		Image:                   "foo",
+		Compatible:              true,
+		MissingBinaries:         []string{},
+		MissingOptionalBinaries: []string{},
+	}
+
+	incompatible := &imageCheckResult{
+		Image:                   "foo",
+		MissingBinaries:         []string{"capsh"},
+		MissingOptionalBinaries: []string{"updatedb"},
+		MissingSudoGroup:        true,
+	}
+
+	testCases := []struct {
+		name   string
+		result *imageCheckResult
+		format string
+		output string
+		errMsg string
+	}{
+		{
+			name:   "Compatible",
+			result: compatible,
+			output: "Image foo can be used for toolbox containers\n",
+		},
+		{
+			name:   "Incompatible",
+			result: incompatible,
+			errMsg: "image foo can't be used for toolbox containers\n" +
+				"Missing binary capsh\n" +
+				"Missing group sudo or wheel",
+		},
+		{
+			name:   "Compatible as JSON",
+			result: compatible,
+			format: "json",
+			output: "{\n" +
+				"  \"image\": \"foo\",\n" +
+				"  \"compatible\": true,\n" +
+				"  \"missingBinaries\": [],\n" +
+				"  \"missingOptionalBinaries\": [],\n" +
+				"  \"missingSudoGroup\": false,\n" +
+				"  \"missingProfileD\": false\n" +
+				"}\n",
+		},
+		{
+			name:   "Incompatible as JSON",
+			result: incompatible,
+			format: "json",
+			output: "{\n" +
+				"  \"image\": \"foo\",\n" +
+				"  \"compatible\": false,\n" +
+				"  \"missingBinaries\": [\n" +
+				"    \"capsh\"\n" +
+				"  ],\n" +
+				"  \"missingOptionalBinaries\": [\n" +
+				"    \"updatedb\"\n" +
+				"  ],\n" +
+				"  \"missingSudoGroup\": true,\n" +
+				"  \"missingProfileD\": false\n" +
+				"}\n",
+			errMsg: "image foo can't be used for toolbox containers",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var output strings.Builder
+
+			err := imageCheckOutput(&output, tc.result, tc.format)
+
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+
+			assert.Equal(t, tc.output, output.String())
+		})
+	}
+}
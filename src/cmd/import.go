@@ -0,0 +1,310 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	importFlags struct {
+		container string
+	}
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import a toolbox container from a file",
+	RunE:  importArchive,
+}
+
+func init() {
+	flags := importCmd.Flags()
+
+	flags.StringVarP(&importFlags.container,
+		"container",
+		"c",
+		"",
+		"Assign a different name to the toolbox container")
+
+	importCmd.SetHelpFunc(importHelp)
+	rootCmd.AddCommand(importCmd)
+}
+
+func importArchive(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if len(args) == 0 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "missing argument for \"import\"\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	if importFlags.container != "" && !utils.IsContainerNameValid(importFlags.container) {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "invalid argument for '--container'\n")
+		fmt.Fprintf(&builder, "Container names must match '%s'\n", utils.ContainerNameRegexp)
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	if err := importContainer(args[0], importFlags.container); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func importContainer(archive, container string) error {
+	workDir, err := ioutil.TempDir("", "toolbox-import")
+	if err != nil {
+		return errors.New("failed to create temporary directory")
+	}
+
+	defer os.RemoveAll(workDir)
+
+	metadata, imageArchive, err := readExportArchive(archive, workDir)
+	if err != nil {
+		return err
+	}
+
+	if container == "" {
+		container = metadata.Container
+	}
+
+	logrus.Debugf("Checking if container %s already exists", container)
+
+	if exists, _ := engine.ContainerExists(container); exists {
+		err := createErrorContainerExists(container)
+		return err
+	}
+
+	s := spinner.New(spinner.CharSets[9], 500*time.Millisecond)
+
+	stdoutFd := os.Stdout.Fd()
+	stdoutFdInt := int(stdoutFd)
+	if logLevel := logrus.GetLevel(); logLevel < logrus.DebugLevel && term.IsTerminal(stdoutFdInt) {
+		s.Prefix = fmt.Sprintf("Loading image %s: ", metadata.Image)
+		s.Writer = os.Stdout
+		s.Start()
+		defer s.Stop()
+	}
+
+	logrus.Debugf("Loading image %s from %s", metadata.Image, imageArchive)
+
+	if err := engine.Load(imageArchive); err != nil {
+		return fmt.Errorf("failed to load image %s from %s", metadata.Image, archive)
+	}
+
+	info, err := engine.Inspect("image", metadata.Image)
+	if err != nil {
+		logrus.Debugf("Inspecting image %s failed: %s", metadata.Image, err)
+		return fmt.Errorf("invalid archive %s: image %s not loaded", archive, metadata.Image)
+	}
+
+	// The name of the image in the archive only depends on the name of the
+	// exported container, so the next import of a container with the same
+	// name would take it over. It's replaced with one that is unique to
+	// the image.
+	image := fmt.Sprintf("localhost/toolbox-import-%s:%s",
+		strings.ToLower(metadata.Container),
+		utils.ShortID(info.ID))
+
+	logrus.Debugf("Tagging image %s as %s", metadata.Image, image)
+
+	if err := engine.Tag(metadata.Image, image); err != nil {
+		return fmt.Errorf("failed to tag image %s as %s", metadata.Image, image)
+	}
+
+	if err := engine.RemoveImage(metadata.Image, false); err != nil {
+		logrus.Debugf("Removing image %s failed: %s", metadata.Image, err)
+	}
+
+	s.Stop()
+
+	options := createOptions{
+		devices: metadata.Devices,
+		env:     metadata.Env,
+		volumes: metadata.Volumes,
+	}
+
+	if err := createContainer(container, image, metadata.Release, options, true); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func importHelp(cmd *cobra.Command, args []string) {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			fmt.Fprintf(os.Stderr, "Error: this is not a toolbox container\n")
+			return
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return
+		}
+
+		return
+	}
+
+	if err := showManual("toolbox-import"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+}
+
+// readExportArchive reads the metadata from an archive created by 'toolbox
+// export', and extracts the image into workDir.
+func readExportArchive(archive, workDir string) (exportMetadata, string, error) {
+	var metadata exportMetadata
+	var metadataFound bool
+	var imageArchive string
+
+	file, err := os.Open(archive)
+	if err != nil {
+		logrus.Debugf("Opening %s failed: %s", archive, err)
+		return metadata, "", fmt.Errorf("failed to open %s", archive)
+	}
+
+	defer file.Close()
+
+	reader := tar.NewReader(file)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			logrus.Debugf("Reading %s failed: %s", archive, err)
+			return metadata, "", fmt.Errorf("failed to read %s", archive)
+		}
+
+		switch header.Name {
+		case exportMetadataFile:
+			decoder := json.NewDecoder(reader)
+			if err := decoder.Decode(&metadata); err != nil {
+				logrus.Debugf("Decoding %s in %s failed: %s", exportMetadataFile, archive, err)
+				return metadata, "", fmt.Errorf("invalid archive %s: failed to decode %s",
+					archive,
+					exportMetadataFile)
+			}
+
+			metadataFound = true
+		case exportImageFile:
+			imageArchive = filepath.Join(workDir, exportImageFile)
+
+			image, err := os.Create(imageArchive)
+			if err != nil {
+				return metadata, "", fmt.Errorf("failed to create %s", imageArchive)
+			}
+
+			_, err = io.Copy(image, reader)
+			image.Close()
+
+			if err != nil {
+				logrus.Debugf("Extracting %s from %s failed: %s", exportImageFile, archive, err)
+				return metadata, "", fmt.Errorf("failed to read %s", archive)
+			}
+		default:
+			logrus.Debugf("Ignoring %s in %s", header.Name, archive)
+		}
+	}
+
+	if !metadataFound {
+		return metadata, "", fmt.Errorf("invalid archive %s: missing %s", archive, exportMetadataFile)
+	}
+
+	if imageArchive == "" {
+		return metadata, "", fmt.Errorf("invalid archive %s: missing %s", archive, exportImageFile)
+	}
+
+	if err := validateExportMetadata(metadata); err != nil {
+		return metadata, "", fmt.Errorf("invalid archive %s: %w", archive, err)
+	}
+
+	return metadata, imageArchive, nil
+}
+
+func validateExportMetadata(metadata exportMetadata) error {
+	if metadata.Version != exportVersion {
+		return fmt.Errorf("unsupported version %d", metadata.Version)
+	}
+
+	if !utils.IsContainerNameValid(metadata.Container) {
+		return fmt.Errorf("invalid container name %s", metadata.Container)
+	}
+
+	if utils.ImageReferenceGetDomain(metadata.Image) != "localhost" {
+		return fmt.Errorf("invalid image %s", metadata.Image)
+	}
+
+	if metadata.Release == "" {
+		return errors.New("missing release")
+	}
+
+	for _, option := range []struct {
+		values   []string
+		validate func(string) error
+	}{
+		{metadata.Devices, utils.ValidateDevice},
+		{metadata.Env, utils.ValidateEnvironmentVariable},
+		{metadata.Volumes, utils.ValidateVolume},
+	} {
+		for _, value := range option.values {
+			if err := option.validate(value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -318,6 +318,10 @@ func initContainer(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if err := runHooks(hookPostInit); err != nil {
+		return err
+	}
+
 	logrus.Debug("Finished initializing container")
 
 	uidString := strconv.Itoa(initContainerFlags.uid)
@@ -440,6 +444,10 @@ func configureUsers(targetUserUid int,
 		}
 	}
 
+	if err := configureSudoers(sudoGroup); err != nil {
+		return err
+	}
+
 	logrus.Debugf("Removing password for user %s", targetUser)
 
 	if err := shell.Run("passwd", nil, nil, nil, "--delete", targetUser); err != nil {
@@ -455,6 +463,31 @@ func configureUsers(targetUserUid int,
 	return nil
 }
 
+// configureSudoers lets the members of sudoGroup use sudo(8). Not all
+// operating systems do that by default. eg., Arch Linux has the line for the
+// wheel group commented out.
+func configureSudoers(sudoGroup string) error {
+	if !utils.PathExists("/etc/sudoers.d") || utils.PathExists("/etc/sudoers.d/toolbox") {
+		return nil
+	}
+
+	logrus.Debugf("Configuring sudo for group %s", sudoGroup)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# Written by Toolbox\n")
+	fmt.Fprintf(&builder, "# https://github.com/containers/toolbox\n")
+	fmt.Fprintf(&builder, "\n")
+	fmt.Fprintf(&builder, "%%%s ALL=(ALL) ALL\n", sudoGroup)
+
+	sudoersString := builder.String()
+	sudoersBytes := []byte(sudoersString)
+	if err := ioutil.WriteFile("/etc/sudoers.d/toolbox", sudoersBytes, 0440); err != nil {
+		return fmt.Errorf("failed to configure sudo for group %s: %w", sudoGroup, err)
+	}
+
+	return nil
+}
+
 func handleDailyTick(event time.Time) {
 	eventString := event.String()
 	logrus.Debugf("Handling daily tick %s", eventString)
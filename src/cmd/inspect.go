@@ -0,0 +1,382 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// toolboxInspect is what 'toolbox inspect' shows about a toolbox container.
+// The JSON encoding is the output of 'toolbox inspect --format json', and
+// changes must be reflected in toolbox-inspect(1).
+type toolboxInspect struct {
+	Name    string `json:"name"`
+	ID      string `json:"id"`
+	Image   string `json:"image"`
+	ImageID string `json:"imageId"`
+
+	// ImageFullyQualified is empty if the image has no registry, and
+	// isn't one of those of the supported distributions.
+	ImageFullyQualified string `json:"imageFullyQualified"`
+
+	Distro  string `json:"distro"`
+	Release string `json:"release"`
+
+	// User, UID, Home and Shell are those given to 'toolbox
+	// init-container', and are empty for containers created by very old
+	// versions of Toolbox.
+	User  string `json:"user"`
+	UID   string `json:"uid"`
+	Home  string `json:"home"`
+	Shell string `json:"shell"`
+
+	Links   []string        `json:"links"`
+	Sockets []inspectSocket `json:"sockets"`
+	Options []string        `json:"options"`
+
+	State string `json:"state"`
+	PID   int    `json:"pid"`
+
+	// InitializedStamp is only set if the container is running.
+	Initialized      bool   `json:"initialized"`
+	InitializedStamp string `json:"initializedStamp,omitempty"`
+}
+
+type inspectSocket struct {
+	Service string `json:"service"`
+	Path    string `json:"path"`
+}
+
+var (
+	inspectFlags struct {
+		format string
+	}
+
+	// inspectLinks are the options of 'toolbox init-container' that make
+	// symbolic links, and the links that they make.
+	inspectLinks = []struct {
+		option string
+		link   string
+	}{
+		{"--home-link", "/home"},
+		{"--media-link", "/media"},
+		{"--mnt-link", "/mnt"},
+	}
+
+	// inspectSockets are the paths where the sockets of the services
+	// that 'toolbox create' looks for are usually found on the host.
+	inspectSockets = []inspectSocket{
+		{"Avahi", "/run/avahi-daemon/socket"},
+		{"KCM", "/run/.heim_org.h5l.kcm-socket"},
+		{"pcsc", "/run/pcscd/pcscd.comm"},
+	}
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Show how a toolbox container was set up",
+	RunE:  inspect,
+}
+
+func init() {
+	flags := inspectCmd.Flags()
+
+	flags.StringVar(&inspectFlags.format,
+		"format",
+		"",
+		"Change the output format to json")
+
+	inspectCmd.SetHelpFunc(inspectHelp)
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func inspect(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if inspectFlags.format != "" && inspectFlags.format != "json" {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "invalid argument for '--format'\n")
+		fmt.Fprintf(&builder, "Supported values are 'json'\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	if len(args) != 1 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "missing argument for \"inspect\"\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	container := args[0]
+
+	logrus.Debugf("Inspecting container %s", container)
+
+	info, err := engine.Inspect("container", container)
+	if err != nil {
+		err := createErrorContainerNotFound(container)
+		return err
+	}
+
+	if !info.IsToolbox() {
+		return fmt.Errorf("%s is not a toolbox container", container)
+	}
+
+	var toolboxRuntimeDirectory string
+
+	if info.State.Running {
+		toolboxRuntimeDirectory, err = utils.GetRuntimeDirectory(currentUser)
+		if err != nil {
+			return err
+		}
+	}
+
+	result := getInspectResult(info, toolboxRuntimeDirectory)
+
+	if err := inspectOutput(os.Stdout, result, inspectFlags.format); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// getInitContainerArguments returns the options given to 'toolbox
+// init-container' by the entry point of a container, with an empty value for
+// those without one. It's empty if the entry point isn't 'toolbox
+// init-container'.
+func getInitContainerArguments(entryPoint []string) map[string]string {
+	arguments := make(map[string]string)
+
+	i := 0
+	for ; i < len(entryPoint); i++ {
+		if entryPoint[i] == "init-container" {
+			break
+		}
+	}
+
+	for i++; i < len(entryPoint); i++ {
+		argument := entryPoint[i]
+		if !strings.HasPrefix(argument, "--") {
+			continue
+		}
+
+		if j := strings.IndexRune(argument, '='); j != -1 {
+			arguments[argument[:j]] = argument[j+1:]
+			continue
+		}
+
+		switch argument {
+		case "--gid", "--home", "--shell", "--uid", "--user":
+			if i+1 < len(entryPoint) {
+				arguments[argument] = entryPoint[i+1]
+				i++
+			}
+		default:
+			arguments[argument] = ""
+		}
+	}
+
+	return arguments
+}
+
+// getInspectResult collects the toolbox-level details of a container from
+// the output of 'podman inspect'. The user and the links are read from the
+// arguments given to the entry point by 'toolbox create'. The
+// initialization stamp is looked for in toolboxRuntimeDirectory if the
+// container is running.
+func getInspectResult(info *podman.InspectResult, toolboxRuntimeDirectory string) toolboxInspect {
+	image := info.ImageName
+	if image == "" {
+		image = info.Image
+	}
+
+	result := toolboxInspect{
+		Name:    strings.TrimPrefix(info.Name, "/"),
+		ID:      info.ID,
+		Image:   image,
+		ImageID: info.Image,
+		Distro:  utils.GetDistroForImage(image),
+		Links:   []string{},
+		Sockets: []inspectSocket{},
+		Options: getCreateOptionsFromLabels(info.Labels),
+		State:   info.State.Status,
+		PID:     info.State.Pid,
+	}
+
+	if result.Distro != "" {
+		result.Release = utils.ImageReferenceGetTag(image)
+	}
+
+	if utils.ImageReferenceHasDomain(image) {
+		result.ImageFullyQualified = image
+	} else if imageFull, err := utils.GetFullyQualifiedImageFromDistros(image,
+		utils.ImageReferenceGetTag(image)); err == nil {
+		result.ImageFullyQualified = imageFull
+	}
+
+	if result.Options == nil {
+		result.Options = []string{}
+	}
+
+	arguments := getInitContainerArguments(info.Config.Cmd)
+	result.User = arguments["--user"]
+	result.UID = arguments["--uid"]
+	result.Home = arguments["--home"]
+	result.Shell = arguments["--shell"]
+
+	for _, link := range inspectLinks {
+		if _, found := arguments[link.option]; found {
+			result.Links = append(result.Links, link.link)
+		}
+	}
+
+	for _, socket := range inspectSockets {
+		for _, mount := range info.Mounts {
+			if mount.Destination == socket.Path || mount.Destination == "/var"+socket.Path {
+				result.Sockets = append(result.Sockets, inspectSocket{socket.Service, mount.Destination})
+				break
+			}
+		}
+	}
+
+	if toolboxRuntimeDirectory != "" && info.State.Running && info.State.Pid > 0 {
+		result.InitializedStamp = fmt.Sprintf("%s/container-initialized-%d",
+			toolboxRuntimeDirectory,
+			info.State.Pid)
+
+		result.Initialized = utils.PathExists(result.InitializedStamp)
+	}
+
+	return result
+}
+
+func inspectHelp(cmd *cobra.Command, args []string) {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			fmt.Fprintf(os.Stderr, "Error: this is not a toolbox container\n")
+			return
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return
+		}
+
+		return
+	}
+
+	if err := showManual("toolbox-inspect"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+}
+
+func inspectOutput(writer io.Writer, result toolboxInspect, format string) error {
+	if format == "json" {
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode details of container %s: %w", result.Name, err)
+		}
+
+		fmt.Fprintf(writer, "%s\n", resultJSON)
+		return nil
+	}
+
+	orUnknown := func(value string) string {
+		if value == "" {
+			return "<unknown>"
+		}
+
+		return value
+	}
+
+	orNone := func(values []string) string {
+		if len(values) == 0 {
+			return "none"
+		}
+
+		return strings.Join(values, ", ")
+	}
+
+	var sockets []string
+	for _, socket := range result.Sockets {
+		sockets = append(sockets, fmt.Sprintf("%s (%s)", socket.Service, socket.Path))
+	}
+
+	options := strings.Join(result.Options, " ")
+	if options == "" {
+		options = "none"
+	}
+
+	user := orUnknown(result.User)
+	if result.UID != "" {
+		user = fmt.Sprintf("%s (UID %s)", user, result.UID)
+	}
+
+	initialized := "no"
+	if result.Initialized {
+		initialized = fmt.Sprintf("yes (%s)", result.InitializedStamp)
+	} else if !strings.EqualFold(result.State, "running") {
+		initialized = "no, not running"
+	}
+
+	tabWriter := tabwriter.NewWriter(writer, 20, 8, 1, ' ', 0)
+
+	fmt.Fprintf(tabWriter, "Name:\t%s\n", result.Name)
+	fmt.Fprintf(tabWriter, "ID:\t%s\n", result.ID)
+	fmt.Fprintf(tabWriter, "Image:\t%s\n", result.Image)
+	fmt.Fprintf(tabWriter, "Fully qualified:\t%s\n", orUnknown(result.ImageFullyQualified))
+	fmt.Fprintf(tabWriter, "Image ID:\t%s\n", result.ImageID)
+	fmt.Fprintf(tabWriter, "Distribution:\t%s\n", orUnknown(result.Distro))
+	fmt.Fprintf(tabWriter, "Release:\t%s\n", orUnknown(result.Release))
+	fmt.Fprintf(tabWriter, "User:\t%s\n", user)
+	fmt.Fprintf(tabWriter, "Home:\t%s\n", orUnknown(result.Home))
+	fmt.Fprintf(tabWriter, "Shell:\t%s\n", orUnknown(result.Shell))
+	fmt.Fprintf(tabWriter, "Links:\t%s\n", orNone(result.Links))
+	fmt.Fprintf(tabWriter, "Sockets:\t%s\n", orNone(sockets))
+	fmt.Fprintf(tabWriter, "Options:\t%s\n", options)
+	fmt.Fprintf(tabWriter, "State:\t%s\n", result.State)
+	fmt.Fprintf(tabWriter, "Initialized:\t%s\n", initialized)
+
+	tabWriter.Flush()
+	return nil
+}
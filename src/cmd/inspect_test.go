@@ -0,0 +1,143 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newInspectTestData() *podman.InspectResult {
+	_, containers := newFakeToolboxData()
+
+	info := newFakeInspectResult(containers[0])
+	info.ImageName = "fedora-toolbox:34"
+	info.Mounts = []podman.Mount{
+		{Type: "bind", Source: "/run/avahi-daemon/socket", Destination: "/run/avahi-daemon/socket"},
+		{Type: "bind", Source: "/run/pcscd/pcscd.comm", Destination: "/run/pcscd/pcscd.comm"},
+		{Type: "bind", Source: "/home/user", Destination: "/home/user"},
+	}
+
+	info.Config.Cmd = append(info.Config.Cmd, "--home-link", "--mnt-link")
+	info.State.Pid = 4242
+
+	return info
+}
+
+func TestGetInspectResult(t *testing.T) {
+	runtimeDirectory, err := ioutil.TempDir("", "toolbox-runtime")
+	require.NoError(t, err)
+	defer os.RemoveAll(runtimeDirectory)
+
+	info := newInspectTestData()
+
+	result := getInspectResult(info, runtimeDirectory)
+	assert.False(t, result.Initialized)
+
+	stamp := filepath.Join(runtimeDirectory, "container-initialized-4242")
+	err = ioutil.WriteFile(stamp, nil, 0644)
+	require.NoError(t, err)
+
+	result = getInspectResult(info, runtimeDirectory)
+
+	assert.Equal(t, toolboxInspect{
+		Name:                "fedora-toolbox-34",
+		ID:                  "c0ffee",
+		Image:               "fedora-toolbox:34",
+		ImageID:             "4f3c5c3b9d6b",
+		ImageFullyQualified: "registry.fedoraproject.org/fedora-toolbox:34",
+		Distro:              "fedora",
+		Release:             "34",
+		User:                "user",
+		UID:                 "1000",
+		Home:                "/home/user",
+		Shell:               "/bin/bash",
+		Links:               []string{"/home", "/mnt"},
+		Sockets: []inspectSocket{
+			{"Avahi", "/run/avahi-daemon/socket"},
+			{"pcsc", "/run/pcscd/pcscd.comm"},
+		},
+		Options:          []string{"--device", "/dev/kvm"},
+		State:            "running",
+		PID:              4242,
+		Initialized:      true,
+		InitializedStamp: stamp,
+	}, result)
+}
+
+func TestGetInspectResultOldContainer(t *testing.T) {
+	info := &podman.InspectResult{
+		ID:    "decaf",
+		Name:  "custom",
+		Image: "localhost/custom:latest",
+	}
+
+	info.Config.Cmd = []string{"toolbox", "init-container", "--user=user", "--uid=1000"}
+	info.State.Status = "exited"
+
+	result := getInspectResult(info, "")
+
+	assert.Equal(t, "localhost/custom:latest", result.ImageFullyQualified)
+	assert.Equal(t, "", result.Distro)
+	assert.Equal(t, "user", result.User)
+	assert.Equal(t, "1000", result.UID)
+	assert.Empty(t, result.Links)
+	assert.Empty(t, result.Sockets)
+	assert.Equal(t, []string{}, result.Options)
+	assert.False(t, result.Initialized)
+	assert.Equal(t, "", result.InitializedStamp)
+}
+
+func TestInspectOutput(t *testing.T) {
+	result := getInspectResult(newInspectTestData(), "")
+
+	var output strings.Builder
+	err := inspectOutput(&output, result, "")
+	require.NoError(t, err)
+
+	expected := `Name:               fedora-toolbox-34
+ID:                 c0ffee
+Image:              fedora-toolbox:34
+Fully qualified:    registry.fedoraproject.org/fedora-toolbox:34
+Image ID:           4f3c5c3b9d6b
+Distribution:       fedora
+Release:            34
+User:               user (UID 1000)
+Home:               /home/user
+Shell:              /bin/bash
+Links:              /home, /mnt
+Sockets:            Avahi (/run/avahi-daemon/socket), pcsc (/run/pcscd/pcscd.comm)
+Options:            --device /dev/kvm
+State:              running
+Initialized:        no
+`
+
+	assert.Equal(t, expected, output.String())
+
+	output.Reset()
+	err = inspectOutput(&output, result, "json")
+	require.NoError(t, err)
+	assert.Contains(t, output.String(), "\"imageFullyQualified\": \"registry.fedoraproject.org/fedora-toolbox:34\"")
+	assert.NotContains(t, output.String(), "initializedStamp")
+}
@@ -20,36 +20,115 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/containers/toolbox/pkg/podman"
 	"github.com/containers/toolbox/pkg/utils"
+	"github.com/docker/go-units"
 	"github.com/mattn/go-isatty"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+// toolboxImage is a toolbox image as shown by 'toolbox list'. The JSON
+// encoding is part of the output of 'toolbox list --format json', and the
+// fields can be used in Go templates given to --format. Changes must be
+// reflected in toolbox-list(1).
 type toolboxImage struct {
-	ID      string
-	Names   []string
-	Created string
-	Labels  map[string]string
+	ID     string            `json:"id"`
+	Names  []string          `json:"names"`
+	Digest string            `json:"digest"`
+	Labels map[string]string `json:"labels"`
+
+	// RepoDigests are the digests that the image was pulled by, which are
+	// compared with those in registries by --outdated.
+	RepoDigests []string `json:"-"`
+
+	// Size is the disk usage of the image in bytes, or 0 if unknown.
+	Size int64 `json:"size"`
+
+	// Created is a human-readable string, like "5 minutes ago", and
+	// CreatedAt is the time stamp that it's based on. CreatedAt is the zero
+	// time.Time if Podman is too old to provide one.
+	Created   string    `json:"-"`
+	CreatedAt time.Time `json:"created"`
 }
 
+// toolboxContainer is a toolbox container as shown by 'toolbox list'. Like
+// toolboxImage, its JSON encoding and fields are part of the documented
+// output of 'toolbox list --format'.
 type toolboxContainer struct {
-	ID      string
-	Names   []string
-	Status  string
-	Created string
-	Image   string
-	Labels  map[string]string
+	ID      string            `json:"id"`
+	Names   []string          `json:"names"`
+	State   string            `json:"state"`
+	Image   string            `json:"image"`
+	ImageID string            `json:"imageId"`
+	Labels  map[string]string `json:"labels"`
+	Options []string          `json:"options"`
+
+	Created   string    `json:"-"`
+	CreatedAt time.Time `json:"created"`
+
+	// Distro and Release are empty if the image isn't known to be one
+	// of those of the supported distributions.
+	Distro  string `json:"distro"`
+	Release string `json:"release"`
+
+	// LastEntered is nil if the container was never entered. It's only
+	// set if it was asked for with --columns, like Sessions and Size.
+	LastEntered *time.Time `json:"lastEntered,omitempty"`
+	Sessions    *int       `json:"sessions,omitempty"`
+	Size        *int64     `json:"size,omitempty"`
+}
+
+// listJSON is the output of 'toolbox list --format json'. Both lists are
+// always present, even if empty or not asked for.
+type listJSON struct {
+	Containers []toolboxContainer `json:"containers"`
+	Images     []toolboxImage     `json:"images"`
 }
 
 var (
 	listFlags struct {
+		columns        []string
+		filters        []string
+		format         string
 		onlyContainers bool
 		onlyImages     bool
+		outdated       bool
+		sort           string
+	}
+
+	// listColumns are the optional columns for containers, in the order
+	// of their headers.
+	listColumns = []struct {
+		name   string
+		header string
+	}{
+		{"distro", "DISTRO"},
+		{"entered", "LAST ENTERED"},
+		{"release", "RELEASE"},
+		{"sessions", "SESSIONS"},
+		{"size", "SIZE"},
+	}
+
+	// listTemplateFuncs are the functions available to Go templates given
+	// to --format, in addition to the built-in ones.
+	listTemplateFuncs = template.FuncMap{
+		"join": strings.Join,
+		"json": func(value interface{}) (string, error) {
+			valueJSON, err := json.Marshal(value)
+			return string(valueJSON), err
+		},
 	}
 
 	// toolboxLabels holds labels used by containers/images that mark them as compatible with Toolbox
@@ -68,18 +147,43 @@ var listCmd = &cobra.Command{
 func init() {
 	flags := listCmd.Flags()
 
+	flags.StringSliceVar(&listFlags.columns,
+		"columns",
+		nil,
+		"Show more columns for toolbox containers: distro, entered, release, sessions or size")
+
 	flags.BoolVarP(&listFlags.onlyContainers,
 		"containers",
 		"c",
 		false,
 		"List only toolbox containers, not images")
 
+	flags.StringArrayVar(&listFlags.filters,
+		"filter",
+		nil,
+		"Show only toolbox containers and images that match KEY=VALUE")
+
+	flags.StringVar(&listFlags.format,
+		"format",
+		"",
+		"Change the output format to json or a Go template")
+
 	flags.BoolVarP(&listFlags.onlyImages,
 		"images",
 		"i",
 		false,
 		"List only toolbox images, not containers")
 
+	flags.BoolVar(&listFlags.outdated,
+		"outdated",
+		false,
+		"List only toolbox images with updates in their registries, and containers created from them")
+
+	flags.StringVar(&listFlags.sort,
+		"sort",
+		"",
+		"Sort toolbox containers and images by created, name, size or status")
+
 	listCmd.SetHelpFunc(listHelp)
 	rootCmd.AddCommand(listCmd)
 }
@@ -97,6 +201,57 @@ func list(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	for _, column := range listFlags.columns {
+		if getListColumnHeader(column) == "" {
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "invalid argument for '--columns'\n")
+			fmt.Fprintf(&builder, "Supported values are 'distro', 'entered', 'release', 'sessions' and 'size'\n")
+			fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+			errMsg := builder.String()
+			return errors.New(errMsg)
+		}
+	}
+
+	filters, err := parseListFilters(listFlags.filters)
+	if err != nil {
+		return err
+	}
+
+	validSort := listFlags.sort == ""
+	for _, key := range listSortKeys {
+		if listFlags.sort == key {
+			validSort = true
+		}
+	}
+
+	if !validSort {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "invalid argument for '--sort'\n")
+		fmt.Fprintf(&builder, "Supported values are 'created', 'name', 'size' and 'status'\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	var listTemplate *template.Template
+
+	if listFlags.format != "" && listFlags.format != "json" {
+		var err error
+
+		listTemplate, err = template.New("list").Funcs(listTemplateFuncs).Parse(listFlags.format)
+		if err != nil {
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "invalid argument for '--format'\n")
+			fmt.Fprintf(&builder, "%s\n", capitalizeFirst(err.Error()))
+			fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+			errMsg := builder.String()
+			return errors.New(errMsg)
+		}
+	}
+
 	lsContainers := true
 	lsImages := true
 
@@ -108,13 +263,35 @@ func list(cmd *cobra.Command, args []string) error {
 
 	var images []toolboxImage
 	var containers []toolboxContainer
-	var err error
 
-	if lsImages {
-		images, err = getImages()
+	// The outdated images are needed to find the outdated containers, even
+	// if the images aren't listed.
+	var outdatedImages []toolboxImage
+
+	if listFlags.outdated {
+		allImages, err := getImages()
 		if err != nil {
 			return err
 		}
+
+		outdatedImages = getOutdatedImages(allImages)
+	}
+
+	if lsImages {
+		if listFlags.outdated {
+			images = outdatedImages
+		} else {
+			images, err = getImages()
+			if err != nil {
+				return err
+			}
+		}
+
+		images = filterImages(images, filters)
+
+		if listFlags.sort != "" {
+			sortImages(images, listFlags.sort)
+		}
 	}
 
 	if lsContainers {
@@ -122,16 +299,116 @@ func list(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
+
+		containers = filterContainers(containers, filters)
+
+		if listFlags.outdated {
+			containers = filterContainersByImages(containers, outdatedImages)
+		}
+
+		// Sorting by size needs the same details as the size column.
+		columns := listFlags.columns
+		if listFlags.sort == "size" {
+			columns = append([]string{"size"}, columns...)
+		}
+
+		if err := getContainerDetails(containers, columns); err != nil {
+			return err
+		}
+
+		if listFlags.sort != "" {
+			sortContainers(containers, listFlags.sort)
+		}
+	}
+
+	if listFlags.format == "json" {
+		if err := listOutputJSON(os.Stdout, images, containers); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if listTemplate != nil {
+		if err := listOutputTemplate(os.Stdout, listTemplate, images, containers); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	listOutput(images, containers, listFlags.columns)
+	return nil
+}
+
+// getContainerDetails fills in the details of containers needed by the
+// optional columns, which are too expensive to always get. All the containers
+// are inspected together, to avoid running one 'podman' process for each.
+func getContainerDetails(containers []toolboxContainer, columns []string) error {
+	var needsLastEntered, needsSessions, needsSize bool
+
+	for _, column := range columns {
+		switch column {
+		case "entered":
+			needsLastEntered = true
+		case "sessions":
+			needsSessions = true
+		case "size":
+			needsSize = true
+		}
+	}
+
+	if needsLastEntered {
+		for i := range containers {
+			containers[i].LastEntered = getLastEntered(containers[i])
+		}
+	}
+
+	if needsSessions {
+		for i := range containers {
+			sessions := getSessions(containers[i])
+			containers[i].Sessions = &sessions
+		}
+	}
+
+	if !needsSize || len(containers) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(containers))
+	for _, container := range containers {
+		ids = append(ids, container.ID)
+	}
+
+	logrus.Debug("Inspecting all containers")
+
+	infos, err := engine.InspectContainers(ids, true)
+	if err != nil {
+		logrus.Debugf("Inspecting all containers failed: %s", err)
+		return errors.New("failed to inspect containers")
+	}
+
+	infosByID := make(map[string]podman.InspectResult, len(infos))
+	for _, info := range infos {
+		infosByID[info.ID] = info
+	}
+
+	for i := range containers {
+		info, found := infosByID[containers[i].ID]
+		if !found {
+			continue
+		}
+
+		size := info.SizeRw
+		containers[i].Size = &size
 	}
 
-	listOutput(images, containers)
 	return nil
 }
 
 func getContainers() ([]toolboxContainer, error) {
 	logrus.Debug("Fetching all containers")
-	args := []string{"--all", "--sort", "names"}
-	containers, err := podman.GetContainers(args...)
+	containers, err := engine.ListContainers()
 	if err != nil {
 		logrus.Debugf("Fetching all containers failed: %s", err)
 		return nil, errors.New("failed to get containers")
@@ -140,29 +417,17 @@ func getContainers() ([]toolboxContainer, error) {
 	var toolboxContainers []toolboxContainer
 
 	for _, container := range containers {
-		var c toolboxContainer
 		var isToolboxContainer bool = false
 
-		containerJSON, err := json.Marshal(container)
-		if err != nil {
-			logrus.Errorf("failed to marshal container: %v", err)
-			continue
-		}
-
-		err = c.UnmarshalJSON(containerJSON)
-		if err != nil {
-			logrus.Errorf("failed to unmarshal container: %v", err)
-			continue
-		}
-
 		for label := range toolboxLabels {
-			if _, ok := c.Labels[label]; ok {
+			if _, ok := container.Labels[label]; ok {
 				isToolboxContainer = true
 				break
 			}
 		}
 
 		if isToolboxContainer {
+			c := newToolboxContainer(container)
 			toolboxContainers = append(toolboxContainers, c)
 		}
 	}
@@ -191,41 +456,142 @@ func listHelp(cmd *cobra.Command, args []string) {
 	}
 }
 
-func getImages() ([]toolboxImage, error) {
-	logrus.Debug("Fetching all images")
-	args := []string{"--sort", "repository"}
-	images, err := podman.GetImages(args...)
+// getLastEntered returns the last time that a container was entered, or
+// nil if it never was. A stamp older than the container was left behind by a
+// removed container with the same name.
+func getLastEntered(container toolboxContainer) *time.Time {
+	name := container.Name()
+	if name == "" {
+		return nil
+	}
+
+	stamp, err := getLastEnteredStamp(name)
 	if err != nil {
-		logrus.Debugf("Fetching all images failed: %s", err)
-		return nil, errors.New("failed to get images")
+		logrus.Debugf("Finding when container %s was entered failed: %s", name, err)
+		return nil
 	}
 
-	var toolboxImages []toolboxImage
+	fileInfo, err := os.Stat(stamp)
+	if err != nil {
+		return nil
+	}
 
-	for _, image := range images {
-		var i toolboxImage
-		var isToolboxImage bool = false
+	lastEntered := fileInfo.ModTime()
+	if lastEntered.Before(container.CreatedAt) {
+		return nil
+	}
+
+	return &lastEntered
+}
+
+// getListColumnHeader returns the header of an optional column, or an empty
+// string if there's no such column.
+func getListColumnHeader(column string) string {
+	for _, listColumn := range listColumns {
+		if listColumn.name == column {
+			return listColumn.header
+		}
+	}
+
+	return ""
+}
+
+// getListColumnValue returns what an optional column shows for a container.
+func getListColumnValue(container toolboxContainer, column string) string {
+	switch column {
+	case "distro":
+		if container.Distro != "" {
+			return container.Distro
+		}
+	case "entered":
+		if container.LastEntered != nil {
+			return utils.HumanDuration(container.LastEntered.Unix())
+		}
+
+		return "never"
+	case "release":
+		if container.Release != "" {
+			return container.Release
+		}
+	case "sessions":
+		if container.Sessions != nil {
+			return strconv.Itoa(*container.Sessions)
+		}
+	case "size":
+		if container.Size != nil {
+			return units.HumanSize(float64(*container.Size))
+		}
+	}
+
+	return "<unknown>"
+}
+
+// getSessions returns the number of 'toolbox enter' sessions of a container
+// that are active. Stamps left behind by toolbox processes that are gone, for
+// example because they were killed, are removed.
+func getSessions(container toolboxContainer) int {
+	name := container.Name()
+	if name == "" || container.State != "running" {
+		return 0
+	}
+
+	sessionsDirectory, err := getSessionsDirectory(name)
+	if err != nil {
+		logrus.Debugf("Finding sessions of container %s failed: %s", name, err)
+		return 0
+	}
+
+	fileInfos, err := ioutil.ReadDir(sessionsDirectory)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Debugf("Finding sessions of container %s failed: %s", name, err)
+		}
+
+		return 0
+	}
 
-		imageJSON, err := json.Marshal(image)
+	var sessions int
+
+	for _, fileInfo := range fileInfos {
+		pid, err := strconv.Atoi(fileInfo.Name())
 		if err != nil {
-			logrus.Errorf("failed to marshal toolbox image: %v", err)
 			continue
 		}
 
-		err = i.UnmarshalJSON(imageJSON)
-		if err != nil {
-			logrus.Errorf("failed to unmarshal toolbox image: %v", err)
+		// Signal 0 is never sent. It only checks if the process exists.
+		if err := syscall.Kill(pid, 0); err != nil {
+			os.Remove(filepath.Join(sessionsDirectory, fileInfo.Name()))
 			continue
 		}
 
+		sessions++
+	}
+
+	return sessions
+}
+
+func getImages() ([]toolboxImage, error) {
+	logrus.Debug("Fetching all images")
+	images, err := engine.ListImages()
+	if err != nil {
+		logrus.Debugf("Fetching all images failed: %s", err)
+		return nil, errors.New("failed to get images")
+	}
+
+	var toolboxImages []toolboxImage
+
+	for _, image := range images {
+		var isToolboxImage bool = false
+
 		for label := range toolboxLabels {
-			if _, ok := i.Labels[label]; ok {
+			if _, ok := image.Labels[label]; ok {
 				isToolboxImage = true
 				break
 			}
 		}
 
 		if isToolboxImage {
+			i := newToolboxImage(image)
 			toolboxImages = append(toolboxImages, i)
 		}
 	}
@@ -233,7 +599,7 @@ func getImages() ([]toolboxImage, error) {
 	return toolboxImages, nil
 }
 
-func listOutput(images []toolboxImage, containers []toolboxContainer) {
+func listOutput(images []toolboxImage, containers []toolboxContainer, columns []string) {
 	if len(images) != 0 {
 		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintf(writer, "%s\t%s\t%s\n", "IMAGE ID", "IMAGE NAME", "CREATED")
@@ -265,6 +631,16 @@ func listOutput(images []toolboxImage, containers []toolboxContainer) {
 		stdoutFd := os.Stdout.Fd()
 		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
+		// Only show the options given to 'toolbox create' if there are
+		// any, to keep the output unchanged for everybody else.
+		showOptions := false
+		for _, container := range containers {
+			if len(container.Options) != 0 {
+				showOptions = true
+				break
+			}
+		}
+
 		if isatty.IsTerminal(stdoutFd) {
 			fmt.Fprintf(writer, "%s", defaultColor)
 		}
@@ -277,6 +653,14 @@ func listOutput(images []toolboxImage, containers []toolboxContainer) {
 			"STATUS",
 			"IMAGE NAME")
 
+		for _, column := range columns {
+			fmt.Fprintf(writer, "\t%s", getListColumnHeader(column))
+		}
+
+		if showOptions {
+			fmt.Fprintf(writer, "\t%s", "OPTIONS")
+		}
+
 		if isatty.IsTerminal(stdoutFd) {
 			fmt.Fprintf(writer, "%s", resetColor)
 		}
@@ -285,8 +669,8 @@ func listOutput(images []toolboxImage, containers []toolboxContainer) {
 
 		for _, container := range containers {
 			isRunning := false
-			if podman.CheckVersion("2.0.0") {
-				isRunning = container.Status == "running"
+			if podman.CheckVersion(engine, "2.0.0") {
+				isRunning = container.State == "running"
 			}
 
 			if isatty.IsTerminal(stdoutFd) {
@@ -302,11 +686,19 @@ func listOutput(images []toolboxImage, containers []toolboxContainer) {
 
 			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s",
 				utils.ShortID(container.ID),
-				container.Names[0],
+				container.Name(),
 				container.Created,
-				container.Status,
+				container.State,
 				container.Image)
 
+			for _, column := range columns {
+				fmt.Fprintf(writer, "\t%s", getListColumnValue(container, column))
+			}
+
+			if showOptions {
+				fmt.Fprintf(writer, "\t%s", strings.Join(container.Options, " "))
+			}
+
 			if isatty.IsTerminal(stdoutFd) {
 				fmt.Fprintf(writer, "%s", resetColor)
 			}
@@ -318,87 +710,139 @@ func listOutput(images []toolboxImage, containers []toolboxContainer) {
 	}
 }
 
-func (i *toolboxImage) UnmarshalJSON(data []byte) error {
-	var raw struct {
-		ID      string
-		Names   []string
-		Created interface{}
-		Labels  map[string]string
+// listOutputJSON writes the containers and images as a listJSON. Missing
+// lists are written as empty arrays and not null, to keep the schema stable.
+func listOutputJSON(writer io.Writer, images []toolboxImage, containers []toolboxContainer) error {
+	output := listJSON{
+		Containers: containers,
+		Images:     images,
 	}
 
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return err
+	if output.Containers == nil {
+		output.Containers = []toolboxContainer{}
 	}
 
-	i.ID = raw.ID
-	i.Names = raw.Names
-	// Until Podman 2.0.x the field 'Created' held a human-readable string in
-	// format "5 minutes ago". Since Podman 2.1 the field holds an integer with
-	// Unix time. Go interprets numbers in JSON as float64.
-	switch value := raw.Created.(type) {
-	case string:
-		i.Created = value
-	case float64:
-		i.Created = utils.HumanDuration(int64(value))
+	if output.Images == nil {
+		output.Images = []toolboxImage{}
 	}
 
-	i.Labels = raw.Labels
+	outputJSON, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode list of containers and images: %w", err)
+	}
 
+	fmt.Fprintf(writer, "%s\n", outputJSON)
 	return nil
 }
 
-func (c *toolboxContainer) UnmarshalJSON(data []byte) error {
-	var raw struct {
-		ID      string
-		Names   interface{}
-		Status  string
-		State   interface{}
-		Created interface{}
-		Image   string
-		Labels  map[string]string
+// listOutputTemplate executes listTemplate once for every image and then
+// once for every container, each followed by a new line.
+func listOutputTemplate(writer io.Writer,
+	listTemplate *template.Template,
+	images []toolboxImage,
+	containers []toolboxContainer) error {
+	var items []interface{}
+
+	for _, image := range images {
+		items = append(items, image)
 	}
 
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return err
+	for _, container := range containers {
+		items = append(items, container)
 	}
 
-	c.ID = raw.ID
-	// In Podman V1 the field 'Names' held a single string but since Podman V2 the
-	// field holds an array of strings
-	switch value := raw.Names.(type) {
-	case string:
-		c.Names = append(c.Names, value)
-	case []interface{}:
-		for _, v := range value {
-			c.Names = append(c.Names, v.(string))
-		}
-	}
-
-	// In Podman V1 the field holding a string about the container's state was
-	// called 'Status' and field 'State' held a number representing the state. In
-	// Podman V2 the string was moved to 'State' and field 'Status' was dropped.
-	switch value := raw.State.(type) {
-	case string:
-		c.Status = value
-	case float64:
-		c.Status = raw.Status
-	}
-
-	// In Podman V1 the field 'Created' held a human-readable string in format
-	// "5 minutes ago". Since Podman V2 the field holds an integer with Unix time.
-	// After a discussion in https://github.com/containers/podman/issues/6594 the
-	// previous value was moved to field 'CreatedAt'. Since we're already using
-	// the 'github.com/docker/go-units' library, we'll stop using the provided
-	// human-readable string and assemble it ourselves. Go interprets numbers in
-	// JSON as float64.
-	switch value := raw.Created.(type) {
-	case string:
-		c.Created = value
-	case float64:
-		c.Created = utils.HumanDuration(int64(value))
-	}
-	c.Image = raw.Image
-	c.Labels = raw.Labels
+	for _, item := range items {
+		var builder strings.Builder
+		if err := listTemplate.Execute(&builder, item); err != nil {
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "invalid argument for '--format'\n")
+			fmt.Fprintf(&builder, "%s\n", capitalizeFirst(err.Error()))
+			fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+			errMsg := builder.String()
+			return errors.New(errMsg)
+		}
+
+		fmt.Fprintf(writer, "%s\n", builder.String())
+	}
 
 	return nil
 }
+
+// Name returns the first name of the container, so that templates given to
+// --format can use .Name instead of indexing .Names.
+func (container toolboxContainer) Name() string {
+	return utils.FirstName(container.Names)
+}
+
+// Name returns the first name of the image, or an empty string if it has none.
+func (image toolboxImage) Name() string {
+	return utils.FirstName(image.Names)
+}
+
+func newToolboxContainer(container podman.Container) toolboxContainer {
+	c := toolboxContainer{
+		ID:        container.ID,
+		Names:     container.Names,
+		State:     container.Status,
+		Created:   formatCreated(container.Created, container.CreatedRelative),
+		CreatedAt: container.Created,
+		Image:     container.Image,
+		ImageID:   container.ImageID,
+		Labels:    container.Labels,
+		Options:   getCreateOptionsFromLabels(container.Labels),
+	}
+
+	if distro := utils.GetDistroForImage(c.Image); distro != "" {
+		c.Distro = distro
+		c.Release = utils.ImageReferenceGetTag(c.Image)
+	}
+
+	if c.Names == nil {
+		c.Names = []string{}
+	}
+
+	if c.Labels == nil {
+		c.Labels = map[string]string{}
+	}
+
+	if c.Options == nil {
+		c.Options = []string{}
+	}
+
+	return c
+}
+
+func newToolboxImage(image podman.Image) toolboxImage {
+	i := toolboxImage{
+		ID:          image.ID,
+		Names:       image.Names,
+		Digest:      image.Digest,
+		RepoDigests: image.RepoDigests,
+		Size:        image.Size,
+		Created:     formatCreated(image.Created, image.CreatedRelative),
+		CreatedAt:   image.Created,
+		Labels:      image.Labels,
+	}
+
+	if i.Names == nil {
+		i.Names = []string{}
+	}
+
+	if i.Labels == nil {
+		i.Labels = map[string]string{}
+	}
+
+	return i
+}
+
+// formatCreated assembles a human-readable string from the creation time
+// reported by Podman. Old versions of Podman only provided such a string
+// instead of a time stamp.
+func formatCreated(created time.Time, createdRelative string) string {
+	if created.IsZero() {
+		return createdRelative
+	}
+
+	return utils.HumanDuration(created.Unix())
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/containers/toolbox/pkg/utils"
+)
+
+// listFilter is a KEY=VALUE given to 'toolbox list --filter'.
+type listFilter struct {
+	key   string
+	value string
+}
+
+var (
+	listFilterKeys = []string{"distro", "image", "label", "name", "release", "status"}
+
+	listSortKeys = []string{"created", "name", "size", "status"}
+)
+
+// filterContainers returns the containers that match the filters. Filters
+// with the same key match if any of them does, and filters with different
+// keys must all match.
+func filterContainers(containers []toolboxContainer, filters []listFilter) []toolboxContainer {
+	if len(filters) == 0 {
+		return containers
+	}
+
+	var filtered []toolboxContainer
+
+	for _, container := range containers {
+		matches := func(filter listFilter) bool {
+			switch filter.key {
+			case "distro":
+				return container.Distro == filter.value
+			case "image":
+				return matchImageName(container.Image, filter.value) ||
+					strings.HasPrefix(container.ImageID, filter.value)
+			case "label":
+				return matchLabel(container.Labels, filter.value)
+			case "name":
+				for _, name := range container.Names {
+					if matched, _ := path.Match(filter.value, name); matched {
+						return true
+					}
+				}
+			case "release":
+				return container.Release == filter.value
+			case "status":
+				return container.State == filter.value
+			}
+
+			return false
+		}
+
+		if matchListFilters(filters, matches) {
+			filtered = append(filtered, container)
+		}
+	}
+
+	return filtered
+}
+
+// filterContainersByImages returns the containers that were created from one
+// of the images.
+func filterContainersByImages(containers []toolboxContainer, images []toolboxImage) []toolboxContainer {
+	imageIDs := make(map[string]bool, len(images))
+	for _, image := range images {
+		imageIDs[image.ID] = true
+	}
+
+	var filtered []toolboxContainer
+
+	for _, container := range containers {
+		if imageIDs[container.ImageID] {
+			filtered = append(filtered, container)
+		}
+	}
+
+	return filtered
+}
+
+// filterImages is the counterpart of filterContainers for images. The name
+// and image filters are the same for images, and the status filter never
+// matches, because images don't have one.
+func filterImages(images []toolboxImage, filters []listFilter) []toolboxImage {
+	if len(filters) == 0 {
+		return images
+	}
+
+	var filtered []toolboxImage
+
+	for _, image := range images {
+		matches := func(filter listFilter) bool {
+			switch filter.key {
+			case "distro", "release":
+				for _, name := range image.Names {
+					distro := utils.GetDistroForImage(name)
+					if distro == "" {
+						continue
+					}
+
+					if filter.key == "distro" && distro == filter.value {
+						return true
+					}
+
+					if filter.key == "release" && utils.ImageReferenceGetTag(name) == filter.value {
+						return true
+					}
+				}
+			case "image", "name":
+				for _, name := range image.Names {
+					if matchImageName(name, filter.value) {
+						return true
+					}
+				}
+
+				return filter.key == "image" && strings.HasPrefix(image.ID, filter.value)
+			case "label":
+				return matchLabel(image.Labels, filter.value)
+			}
+
+			return false
+		}
+
+		if matchListFilters(filters, matches) {
+			filtered = append(filtered, image)
+		}
+	}
+
+	return filtered
+}
+
+// matchImageName checks if the name of an image matches a glob pattern,
+// either in full or without the registry and repository, so that
+// 'fedora-toolbox:*' works as expected.
+func matchImageName(image, pattern string) bool {
+	if matched, _ := path.Match(pattern, image); matched {
+		return true
+	}
+
+	if i := strings.LastIndex(image, "/"); i != -1 {
+		if matched, _ := path.Match(pattern, image[i+1:]); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchLabel checks if labels have the label given by a filter as KEY or
+// KEY=VALUE.
+func matchLabel(labels map[string]string, filterValue string) bool {
+	key, value, hasValue := filterValue, "", false
+	if i := strings.IndexRune(filterValue, '='); i != -1 {
+		key, value, hasValue = filterValue[:i], filterValue[i+1:], true
+	}
+
+	labelValue, found := labels[key]
+	if !found {
+		return false
+	}
+
+	return !hasValue || labelValue == value
+}
+
+func matchListFilters(filters []listFilter, matches func(listFilter) bool) bool {
+	matchedKeys := make(map[string]bool)
+
+	for _, filter := range filters {
+		if _, found := matchedKeys[filter.key]; !found {
+			matchedKeys[filter.key] = false
+		}
+
+		if matches(filter) {
+			matchedKeys[filter.key] = true
+		}
+	}
+
+	for _, matched := range matchedKeys {
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseListFilters(filters []string) ([]listFilter, error) {
+	var listFilters []listFilter
+
+	for _, filter := range filters {
+		var err error
+
+		i := strings.IndexRune(filter, '=')
+		if i == -1 || i == len(filter)-1 {
+			err = fmt.Errorf("filter %s must be KEY=VALUE", filter)
+		} else {
+			key := filter[:i]
+			value := filter[i+1:]
+
+			switch key {
+			case "distro", "label", "release", "status":
+			case "image", "name":
+				if _, patternErr := path.Match(value, ""); patternErr != nil {
+					err = fmt.Errorf("filter %s has an invalid pattern", filter)
+				}
+			default:
+				err = fmt.Errorf("filter %s has an unknown key %s", filter, key)
+			}
+
+			listFilters = append(listFilters, listFilter{key, value})
+		}
+
+		if err != nil {
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "invalid argument for '--filter'\n")
+			fmt.Fprintf(&builder, "%s\n", capitalizeFirst(err.Error()))
+			fmt.Fprintf(&builder, "Supported keys are '%s'\n", strings.Join(listFilterKeys, "', '"))
+			fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+			errMsg := builder.String()
+			return nil, errors.New(errMsg)
+		}
+	}
+
+	return listFilters, nil
+}
+
+// sortContainers sorts containers by a --sort key. Ties, and everything else,
+// are sorted by name.
+func sortContainers(containers []toolboxContainer, key string) {
+	sort.SliceStable(containers, func(i, j int) bool {
+		a, b := containers[i], containers[j]
+
+		switch key {
+		case "created":
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		case "size":
+			var sizeA, sizeB int64
+			if a.Size != nil {
+				sizeA = *a.Size
+			}
+
+			if b.Size != nil {
+				sizeB = *b.Size
+			}
+
+			if sizeA != sizeB {
+				return sizeA < sizeB
+			}
+		case "status":
+			if a.State != b.State {
+				return a.State < b.State
+			}
+		}
+
+		return a.Name() < b.Name()
+	})
+}
+
+// sortImages sorts images like sortContainers. Images don't have a status,
+// so they are sorted by name instead.
+func sortImages(images []toolboxImage, key string) {
+	sort.SliceStable(images, func(i, j int) bool {
+		a, b := images[i], images[j]
+
+		switch key {
+		case "created":
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		}
+
+		return a.Name() < b.Name()
+	})
+}
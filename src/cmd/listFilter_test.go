@@ -0,0 +1,184 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListFilters(t *testing.T) {
+	testCases := []struct {
+		name       string
+		filters    []string
+		images     []string
+		containers []string
+	}{
+		{
+			name:       "No filters",
+			images:     []string{"4f3c5c3b9d6b", "8a1b2c3d4e5f"},
+			containers: []string{"c0ffee", "decaf"},
+		},
+		{
+			name:       "Status",
+			filters:    []string{"status=running"},
+			containers: []string{"c0ffee"},
+		},
+		{
+			name:       "Distro",
+			filters:    []string{"distro=rhel"},
+			images:     []string{"8a1b2c3d4e5f"},
+			containers: []string{"decaf"},
+		},
+		{
+			name:       "Release",
+			filters:    []string{"release=34"},
+			images:     []string{"4f3c5c3b9d6b"},
+			containers: []string{"c0ffee"},
+		},
+		{
+			name:       "Label with value",
+			filters:    []string{"label=vendor=Red Hat"},
+			images:     []string{"8a1b2c3d4e5f"},
+			containers: []string{"decaf"},
+		},
+		{
+			name:    "Label with other value",
+			filters: []string{"label=vendor=Fedora"},
+		},
+		{
+			name:       "Label without value",
+			filters:    []string{"label=vendor"},
+			images:     []string{"8a1b2c3d4e5f"},
+			containers: []string{"decaf"},
+		},
+		{
+			name:       "Image name without registry",
+			filters:    []string{"image=fedora-toolbox:*"},
+			images:     []string{"4f3c5c3b9d6b"},
+			containers: []string{"c0ffee"},
+		},
+		{
+			name:       "Image ID",
+			filters:    []string{"image=8a1b"},
+			images:     []string{"8a1b2c3d4e5f"},
+			containers: []string{"decaf"},
+		},
+		{
+			name:       "Name glob",
+			filters:    []string{"name=rhel-*"},
+			containers: []string{"decaf"},
+		},
+		{
+			name:       "Same key matches any",
+			filters:    []string{"distro=fedora", "distro=rhel"},
+			images:     []string{"4f3c5c3b9d6b", "8a1b2c3d4e5f"},
+			containers: []string{"c0ffee", "decaf"},
+		},
+		{
+			name:       "Different keys match all",
+			filters:    []string{"distro=fedora", "status=exited"},
+			containers: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			images, containers := newListTestData()
+
+			filters, err := parseListFilters(tc.filters)
+			require.NoError(t, err)
+
+			var imageIDs []string
+			for _, image := range filterImages(images, filters) {
+				imageIDs = append(imageIDs, image.ID)
+			}
+
+			var containerIDs []string
+			for _, container := range filterContainers(containers, filters) {
+				containerIDs = append(containerIDs, container.ID)
+			}
+
+			assert.Equal(t, tc.images, imageIDs)
+			assert.Equal(t, tc.containers, containerIDs)
+		})
+	}
+}
+
+func TestParseListFiltersInvalid(t *testing.T) {
+	testCases := []struct {
+		filter string
+		errMsg string
+	}{
+		{"status", "Filter status must be KEY=VALUE"},
+		{"status=", "Filter status= must be KEY=VALUE"},
+		{"size=1", "Filter size=1 has an unknown key size"},
+		{"name=[", "Filter name=[ has an invalid pattern"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.filter, func(t *testing.T) {
+			_, err := parseListFilters([]string{tc.filter})
+			assert.EqualError(t, err, "invalid argument for '--filter'\n"+
+				tc.errMsg+"\n"+
+				"Supported keys are 'distro', 'image', 'label', 'name', 'release', 'status'\n"+
+				"Run '"+executableBase+" --help' for usage.")
+		})
+	}
+}
+
+func TestListSort(t *testing.T) {
+	testCases := []struct {
+		key        string
+		images     []string
+		containers []string
+	}{
+		{"name", []string{"8a1b2c3d4e5f", "4f3c5c3b9d6b"}, []string{"c0ffee", "decaf"}},
+		{"created", []string{"8a1b2c3d4e5f", "4f3c5c3b9d6b"}, []string{"c0ffee", "decaf"}},
+		{"status", []string{"8a1b2c3d4e5f", "4f3c5c3b9d6b"}, []string{"decaf", "c0ffee"}},
+		{"size", []string{"8a1b2c3d4e5f", "4f3c5c3b9d6b"}, []string{"decaf", "c0ffee"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			images, containers := newListTestData()
+
+			sizes := []int64{2000, 1000}
+			for i := range containers {
+				containers[i].Size = &sizes[i]
+			}
+
+			sortImages(images, tc.key)
+			sortContainers(containers, tc.key)
+
+			var imageIDs []string
+			for _, image := range images {
+				imageIDs = append(imageIDs, image.ID)
+			}
+
+			var containerIDs []string
+			for _, container := range containers {
+				containerIDs = append(containerIDs, container.ID)
+			}
+
+			assert.Equal(t, tc.images, imageIDs)
+			assert.Equal(t, tc.containers, containerIDs)
+		})
+	}
+}
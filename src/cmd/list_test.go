@@ -0,0 +1,312 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newListTestData returns the images and containers of newFakeToolboxData as
+// shown by 'toolbox list'.
+func newListTestData() ([]toolboxImage, []toolboxContainer) {
+	podmanImages, podmanContainers := newFakeToolboxData()
+
+	var images []toolboxImage
+	for _, image := range podmanImages {
+		images = append(images, newToolboxImage(image))
+	}
+
+	var containers []toolboxContainer
+	for _, container := range podmanContainers {
+		containers = append(containers, newToolboxContainer(container))
+	}
+
+	return images, containers
+}
+
+func TestListOutputJSON(t *testing.T) {
+	images, containers := newListTestData()
+
+	var output strings.Builder
+	err := listOutputJSON(&output, images[:1], containers[:1])
+	require.NoError(t, err)
+
+	expected := `{
+  "containers": [
+    {
+      "id": "c0ffee",
+      "names": [
+        "fedora-toolbox-34"
+      ],
+      "state": "running",
+      "image": "registry.fedoraproject.org/fedora-toolbox:34",
+      "imageId": "4f3c5c3b9d6b",
+      "labels": {
+        "com.github.containers.toolbox": "true",
+        "com.github.containers.toolbox.devices": "[\"/dev/kvm\"]"
+      },
+      "options": [
+        "--device",
+        "/dev/kvm"
+      ],
+      "created": "2021-06-01T12:00:00Z",
+      "distro": "fedora",
+      "release": "34"
+    }
+  ],
+  "images": [
+    {
+      "id": "4f3c5c3b9d6b",
+      "names": [
+        "registry.fedoraproject.org/fedora-toolbox:34"
+      ],
+      "digest": "",
+      "labels": {
+        "com.github.containers.toolbox": "true"
+      },
+      "size": 300,
+      "created": "2021-06-01T12:00:00Z"
+    }
+  ]
+}
+`
+
+	assert.Equal(t, expected, output.String())
+}
+
+func TestListOutputJSONEmpty(t *testing.T) {
+	var output strings.Builder
+	err := listOutputJSON(&output, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"containers\": [],\n  \"images\": []\n}\n", output.String())
+}
+
+func TestListOutputNoNames(t *testing.T) {
+	_, containers := newListTestData()
+	container := containers[0]
+	container.Names = []string{}
+
+	assert.Nil(t, getLastEntered(container))
+	assert.NotPanics(t, func() {
+		listOutput(nil, []toolboxContainer{container}, nil)
+	})
+}
+
+func TestListOutputTemplate(t *testing.T) {
+	images, containers := newListTestData()
+	images, containers = images[:1], containers[:1]
+
+	testCases := []struct {
+		name       string
+		format     string
+		images     []toolboxImage
+		containers []toolboxContainer
+		output     string
+		errMsg     string
+	}{
+		{
+			name:       "Names",
+			format:     "{{index .Names 0}}",
+			images:     images,
+			containers: containers,
+			output:     "registry.fedoraproject.org/fedora-toolbox:34\nfedora-toolbox-34\n",
+		},
+		{
+			name:       "Name",
+			format:     "{{.Name}}",
+			images:     append(images, toolboxImage{ID: "0123456789ab", Names: []string{}}),
+			containers: containers,
+			output:     "registry.fedoraproject.org/fedora-toolbox:34\n\nfedora-toolbox-34\n",
+		},
+		{
+			name:       "Functions",
+			format:     "{{.ID}} {{.State}} {{join .Options \",\"}} {{json .Labels}} {{.CreatedAt.Unix}}",
+			containers: containers,
+			output: "c0ffee running --device,/dev/kvm " +
+				"{\"com.github.containers.toolbox\":\"true\"," +
+				"\"com.github.containers.toolbox.devices\":\"[\\\"/dev/kvm\\\"]\"} " +
+				"1622548800\n",
+		},
+		{
+			name:   "Missing field",
+			format: "{{.State}}",
+			images: images,
+			errMsg: "invalid argument for '--format'\n" +
+				"Template: list:1:2: executing \"list\" at <.State>: " +
+				"can't evaluate field State in type cmd.toolboxImage\n" +
+				"Run '" + executableBase + " --help' for usage.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			listTemplate, err := template.New("list").Funcs(listTemplateFuncs).Parse(tc.format)
+			require.NoError(t, err)
+
+			var output strings.Builder
+			err = listOutputTemplate(&output, listTemplate, tc.images, tc.containers)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.output, output.String())
+		})
+	}
+}
+
+func TestGetContainerDetails(t *testing.T) {
+	stateDirectory, err := ioutil.TempDir("", "toolbox-state")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateDirectory)
+
+	defer setEnv("XDG_STATE_HOME", stateDirectory)()
+
+	_, containers := newListTestData()
+
+	stamp := filepath.Join(stateDirectory, "toolbox", "last-entered", "fedora-toolbox-34")
+	err = os.MkdirAll(filepath.Dir(stamp), 0700)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(stamp, nil, 0600)
+	require.NoError(t, err)
+
+	lastEntered := time.Date(2021, time.June, 2, 12, 0, 0, 0, time.UTC)
+	err = os.Chtimes(stamp, lastEntered, lastEntered)
+	require.NoError(t, err)
+
+	defer setEnv("XDG_RUNTIME_DIR", stateDirectory)()
+
+	sessionsDirectory, err := getSessionsDirectory("fedora-toolbox-34")
+	require.NoError(t, err)
+	defer os.RemoveAll(sessionsDirectory)
+
+	err = os.MkdirAll(sessionsDirectory, 0700)
+	require.NoError(t, err)
+
+	// Only the session of this process is active. The other stamp was left
+	// behind by a process that is gone.
+	err = ioutil.WriteFile(filepath.Join(sessionsDirectory, strconv.Itoa(os.Getpid())), nil, 0600)
+	require.NoError(t, err)
+
+	staleStamp := filepath.Join(sessionsDirectory, strconv.Itoa(math.MaxInt32))
+	err = ioutil.WriteFile(staleStamp, nil, 0600)
+	require.NoError(t, err)
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.inspectResults["container/c0ffee"] = &podman.InspectResult{
+		ID:     "c0ffee",
+		SizeRw: 2000000,
+	}
+
+	columns := []string{"distro", "release", "size", "entered", "sessions"}
+	err = getContainerDetails(containers[:1], columns)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{{"true", "c0ffee"}}, fake.callsTo("InspectContainers"))
+
+	container := containers[0]
+	require.NotNil(t, container.LastEntered)
+	assert.True(t, lastEntered.Equal(*container.LastEntered))
+
+	var values []string
+	for _, column := range columns {
+		if column == "entered" {
+			continue
+		}
+
+		values = append(values, getListColumnValue(container, column))
+	}
+
+	assert.Equal(t, []string{"fedora", "34", "2MB", "1"}, values)
+	assert.NoFileExists(t, staleStamp)
+}
+
+func TestGetContainerDetailsStaleStamp(t *testing.T) {
+	stateDirectory, err := ioutil.TempDir("", "toolbox-state")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateDirectory)
+
+	defer setEnv("XDG_STATE_HOME", stateDirectory)()
+
+	_, containers := newListTestData()
+
+	stamp := filepath.Join(stateDirectory, "toolbox", "last-entered", "fedora-toolbox-34")
+	err = os.MkdirAll(filepath.Dir(stamp), 0700)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(stamp, nil, 0600)
+	require.NoError(t, err)
+
+	// The stamp was left behind by an older container with the same name.
+	lastEntered := containers[0].CreatedAt.Add(-time.Hour)
+	err = os.Chtimes(stamp, lastEntered, lastEntered)
+	require.NoError(t, err)
+
+	fake, restore := useFakeEngine()
+	defer restore()
+
+	err = getContainerDetails(containers[:1], []string{"entered"})
+	require.NoError(t, err)
+
+	assert.Empty(t, fake.callsTo("InspectContainers"))
+	assert.Nil(t, containers[0].LastEntered)
+	assert.Equal(t, "never", getListColumnValue(containers[0], "entered"))
+}
+
+func TestGetContainerDetailsStoppedSessions(t *testing.T) {
+	runtimeDirectory, err := ioutil.TempDir("", "toolbox-runtime")
+	require.NoError(t, err)
+	defer os.RemoveAll(runtimeDirectory)
+
+	defer setEnv("XDG_RUNTIME_DIR", runtimeDirectory)()
+
+	_, containers := newListTestData()
+	containers = containers[1:]
+
+	// The stamp can't belong to an active session of a stopped container.
+	sessionsDirectory, err := getSessionsDirectory("rhel-toolbox-8.4")
+	require.NoError(t, err)
+	defer os.RemoveAll(sessionsDirectory)
+
+	err = os.MkdirAll(sessionsDirectory, 0700)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(sessionsDirectory, strconv.Itoa(os.Getpid())), nil, 0600)
+	require.NoError(t, err)
+
+	fake, restore := useFakeEngine()
+	defer restore()
+
+	err = getContainerDetails(containers, []string{"sessions"})
+	require.NoError(t, err)
+
+	assert.Empty(t, fake.callsTo("InspectContainers"))
+	assert.Equal(t, "0", getListColumnValue(containers[0], "sessions"))
+}
@@ -76,7 +76,7 @@ func rm(cmd *cobra.Command, args []string) error {
 
 		for _, container := range toolboxContainers {
 			containerID := container.ID
-			if err := podman.RemoveContainer(containerID, rmFlags.forceDelete); err != nil {
+			if err := engine.RemoveContainer(containerID, rmFlags.forceDelete); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 				continue
 			}
@@ -92,12 +92,12 @@ func rm(cmd *cobra.Command, args []string) error {
 		}
 
 		for _, container := range args {
-			if _, err := podman.IsToolboxContainer(container); err != nil {
+			if _, err := podman.IsToolboxContainer(engine, container); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 				continue
 			}
 
-			if err := podman.RemoveContainer(container, rmFlags.forceDelete); err != nil {
+			if err := engine.RemoveContainer(container, rmFlags.forceDelete); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 				continue
 			}
@@ -0,0 +1,114 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRmAll(t *testing.T) {
+	if utils.IsInsideContainer() {
+		t.Skip("commands are forwarded to the host inside containers")
+	}
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.containers = []podman.Container{
+		newFakeToolboxContainer("1", "foo"),
+		{ID: "2", Names: []string{"not-a-toolbox"}},
+		newFakeToolboxContainer("3", "bar"),
+		newFakeToolboxContainer("4", "baz"),
+	}
+
+	fake.errors["RemoveContainer/3"] = errors.New("container bar is running")
+
+	rmFlags.deleteAll = true
+	rmFlags.forceDelete = false
+
+	defer func() {
+		rmFlags.deleteAll = false
+	}()
+
+	err := rm(rmCmd, nil)
+	assert.NoError(t, err)
+
+	expected := [][]string{{"1", "false"}, {"3", "false"}, {"4", "false"}}
+	assert.Equal(t, expected, fake.callsTo("RemoveContainer"))
+}
+
+func TestRmNotToolbox(t *testing.T) {
+	if utils.IsInsideContainer() {
+		t.Skip("commands are forwarded to the host inside containers")
+	}
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.inspectResults["container/foo"] = &podman.InspectResult{
+		Labels: map[string]string{"com.github.containers.toolbox": "true"},
+	}
+
+	fake.inspectResults["container/bar"] = &podman.InspectResult{}
+
+	rmFlags.forceDelete = true
+
+	defer func() {
+		rmFlags.forceDelete = false
+	}()
+
+	err := rm(rmCmd, []string{"foo", "bar", "baz"})
+	assert.NoError(t, err)
+
+	expected := [][]string{{"foo", "true"}}
+	assert.Equal(t, expected, fake.callsTo("RemoveContainer"))
+}
+
+func TestRmiAll(t *testing.T) {
+	if utils.IsInsideContainer() {
+		t.Skip("commands are forwarded to the host inside containers")
+	}
+
+	toolboxLabels := map[string]string{"com.github.debarshiray.toolbox": "true"}
+
+	fake, restore := useFakeEngine()
+	defer restore()
+	fake.images = []podman.Image{
+		{ID: "1", Names: []string{"localhost/foo:latest"}, Labels: toolboxLabels},
+		{ID: "2", Names: []string{"docker.io/library/busybox:latest"}},
+		{ID: "3", Labels: toolboxLabels},
+	}
+
+	fake.errors["RemoveImage/1"] = errors.New("image 1 has dependent children")
+
+	rmiFlags.deleteAll = true
+	rmiFlags.forceDelete = true
+
+	defer func() {
+		rmiFlags.deleteAll = false
+		rmiFlags.forceDelete = false
+	}()
+
+	err := rmi(rmiCmd, nil)
+	assert.NoError(t, err)
+
+	expected := [][]string{{"1", "true"}, {"3", "true"}}
+	assert.Equal(t, expected, fake.callsTo("RemoveImage"))
+}
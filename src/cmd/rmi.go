@@ -76,7 +76,7 @@ func rmi(cmd *cobra.Command, args []string) error {
 
 		for _, image := range toolboxImages {
 			imageID := image.ID
-			if err := podman.RemoveImage(imageID, rmiFlags.forceDelete); err != nil {
+			if err := engine.RemoveImage(imageID, rmiFlags.forceDelete); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 				continue
 			}
@@ -92,12 +92,12 @@ func rmi(cmd *cobra.Command, args []string) error {
 		}
 
 		for _, image := range args {
-			if _, err := podman.IsToolboxImage(image); err != nil {
+			if _, err := podman.IsToolboxImage(engine, image); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 				continue
 			}
 
-			if err := podman.RemoveImage(image, rmiFlags.forceDelete); err != nil {
+			if err := engine.RemoveImage(image, rmiFlags.forceDelete); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 				continue
 			}
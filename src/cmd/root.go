@@ -32,6 +32,7 @@ import (
 	"github.com/containers/toolbox/pkg/version"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
@@ -39,6 +40,8 @@ var (
 
 	currentUser *user.User
 
+	engine podman.Engine
+
 	executable string
 
 	executableBase string
@@ -62,11 +65,32 @@ var (
 )
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		os.Exit(0)
+	}
+
+	// Errors are only shown here once preRun has silenced them, because
+	// Cobra would show errors for commands that exit with the status of
+	// some other command too.
+	if rootCmd.SilenceErrors {
+		if errMsg := err.Error(); errMsg != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", errMsg)
+		}
 	}
 
-	os.Exit(0)
+	var errExit *exitError
+	if errors.As(err, &errExit) {
+		os.Exit(errExit.Code)
+	}
+
+	// 'toolbox run' leaves the other exit codes to the command that it
+	// runs.
+	if cmd == runCmd {
+		os.Exit(runExitCodeToolbox)
+	}
+
+	os.Exit(1)
 }
 
 func init() {
@@ -102,6 +126,7 @@ func init() {
 }
 
 func preRun(cmd *cobra.Command, args []string) error {
+	cmd.Root().SilenceErrors = true
 	cmd.Root().SilenceUsage = true
 
 	if err := setUpLoggers(); err != nil {
@@ -145,11 +170,15 @@ func preRun(cmd *cobra.Command, args []string) error {
 
 	logrus.Debugf("TOOLBOX_PATH is %s", toolboxPath)
 
-	if err := migrate(); err != nil {
+	if err := utils.SetUpConfiguration(); err != nil {
 		return err
 	}
 
-	if err := utils.SetUpConfiguration(); err != nil {
+	if err := setUpEngine(); err != nil {
+		return err
+	}
+
+	if err := migrate(); err != nil {
 		return err
 	}
 
@@ -207,7 +236,7 @@ func migrate() error {
 	stampPath := toolboxConfigDir + "/podman-system-migrate"
 	logrus.Debugf("Toolbox config directory is %s", toolboxConfigDir)
 
-	podmanVersion, err := podman.GetVersion()
+	podmanVersion, err := engine.GetVersion()
 	if err != nil {
 		logrus.Debugf("Migrating to newer Podman: failed to get the Podman version: %s", err)
 		return errors.New("failed to get the Podman version")
@@ -265,14 +294,14 @@ func migrate() error {
 				return nil
 			}
 
-			if !podman.CheckVersion(podmanVersionOld) {
+			if !podman.CheckVersion(engine, podmanVersionOld) {
 				logrus.Debugf("Migration not needed: Podman version %s is old", podmanVersion)
 				return nil
 			}
 		}
 	}
 
-	if err = podman.SystemMigrate(""); err != nil {
+	if err = engine.SystemMigrate(""); err != nil {
 		logrus.Debugf("Migrating to newer Podman: failed to migrate containers: %s", err)
 		return errors.New("failed to migrate containers")
 	}
@@ -303,9 +332,54 @@ func newSubIDFileError() error {
 	return errors.New(errMsg)
 }
 
+func setUpEngine() error {
+	if utils.IsInsideContainer() {
+		return nil
+	}
+
+	backend := "cli"
+	if viper.IsSet("engine.backend") {
+		backend = viper.GetString("engine.backend")
+	}
+
+	logrus.Debugf("Setting up the %s container engine backend", backend)
+
+	switch backend {
+	case "cli":
+		return nil
+	case "api":
+	default:
+		return fmt.Errorf("invalid value %s for engine.backend", backend)
+	}
+
+	var socket string
+
+	if viper.IsSet("engine.socket") {
+		socket = viper.GetString("engine.socket")
+	} else if currentUser.Uid == "0" {
+		socket = "/run/podman/podman.sock"
+	} else {
+		xdgRuntimeDir := os.Getenv("XDG_RUNTIME_DIR")
+		socket = xdgRuntimeDir + "/podman/podman.sock"
+	}
+
+	if !utils.PathExists(socket) {
+		logrus.Debugf("Setting up the api container engine backend: socket %s not found", socket)
+		logrus.Debug("Falling back to the cli container engine backend")
+		return nil
+	}
+
+	logrus.Debugf("Using the Podman service at %s", socket)
+	engine = podman.NewAPIEngine(socket)
+
+	return nil
+}
+
 func setUpGlobals() error {
 	var err error
 
+	engine = podman.NewCLIEngine()
+
 	if !utils.IsInsideContainer() {
 		cgroupsVersion, err = utils.GetCgroupsVersion()
 		if err != nil {
@@ -87,9 +87,11 @@ func rootRunImpl(cmd *cobra.Command, args []string) error {
 		return errors.New("failed to get the host VARIANT_ID")
 	}
 
+	options := runOptions{session: true, tty: isStdioTerminal()}
+
 	var emitEscapeSequence bool
 
-	if hostID == "fedora" && (hostVariantID == "silverblue" || hostVariantID == "workstation") {
+	if options.tty && hostID == "fedora" && (hostVariantID == "silverblue" || hostVariantID == "workstation") {
 		emitEscapeSequence = true
 	}
 
@@ -98,6 +100,7 @@ func rootRunImpl(cmd *cobra.Command, args []string) error {
 		image,
 		release,
 		command,
+		options,
 		emitEscapeSequence,
 		true,
 		false); err != nil {
@@ -19,22 +19,66 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/containers/toolbox/pkg/podman"
-	"github.com/containers/toolbox/pkg/shell"
 	"github.com/containers/toolbox/pkg/utils"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+const (
+	// runExitCodeToolbox, runExitCodeCannotInvoke and runExitCodeNotFound
+	// are the statuses of 'toolbox run' when it fails on its own, like
+	// those of 'podman exec'. Otherwise, it exits with the status of the
+	// command.
+	runExitCodeToolbox      = 125
+	runExitCodeCannotInvoke = 126
+	runExitCodeNotFound     = 127
+)
+
+// runOptions change how a command is run in a container. By default, it's
+// run as the current user without any capabilities, in the current working
+// directory or the home directory, and without a pseudo-terminal.
+type runOptions struct {
+	// env holds NAME[=VALUE] pairs that are set after the preserved
+	// environment variables, so they take precedence.
+	env []string
+
+	tty  bool
+	user string
+
+	// homeDir is the home directory of another user in the container,
+	// which becomes its HOME and the fallback working directory.
+	homeDir string
+
+	// session makes the command count as a 'toolbox enter' session in
+	// 'toolbox list' while it runs.
+	session bool
+
+	// workDir is used instead of the current working directory, without
+	// falling back to the home directory if it's missing.
+	workDir string
+}
+
 var (
 	runFlags struct {
 		container string
 		distro    string
+		env       []string
+		envFiles  []string
+		noTTY     bool
 		release   string
+		root      bool
+		tty       bool
+		user      string
+		workDir   string
 	}
 
 	runFallbackCommands = [][]string{{"/bin/bash", "-l"}}
@@ -63,12 +107,50 @@ func init() {
 		"",
 		"Run command inside a toolbox container for a different operating system distribution than the host")
 
+	flags.StringArrayVarP(&runFlags.env,
+		"env",
+		"e",
+		[]string{},
+		"Set an environment variable for the command, as NAME=VALUE or NAME to take it from the host")
+
+	flags.StringArrayVar(&runFlags.envFiles,
+		"env-file",
+		[]string{},
+		"Read environment variables for the command from a file with one NAME[=VALUE] per line")
+
+	flags.BoolVar(&runFlags.noTTY,
+		"no-tty",
+		false,
+		"Don't allocate a pseudo-terminal for the command, even if the standard input and output are terminals")
+
 	flags.StringVarP(&runFlags.release,
 		"release",
 		"r",
 		"",
 		"Run command inside a toolbox container for a different operating system release than the host")
 
+	flags.BoolVar(&runFlags.root,
+		"root",
+		false,
+		"Run command as root inside the toolbox container, with its capabilities")
+
+	flags.BoolVar(&runFlags.tty,
+		"tty",
+		false,
+		"Allocate a pseudo-terminal for the command, even if the standard input or output isn't a terminal")
+
+	flags.StringVarP(&runFlags.user,
+		"user",
+		"u",
+		"",
+		"Run command as a different user inside the toolbox container")
+
+	flags.StringVarP(&runFlags.workDir,
+		"workdir",
+		"w",
+		"",
+		"Run command in a different working directory inside the toolbox container")
+
 	runCmd.SetHelpFunc(runHelp)
 	rootCmd.AddCommand(runCmd)
 }
@@ -79,13 +161,81 @@ func run(cmd *cobra.Command, args []string) error {
 			return errors.New("this is not a toolbox container")
 		}
 
-		if _, err := utils.ForwardToHost(); err != nil {
+		exitCode, err := utils.ForwardToHost()
+		if err != nil {
 			return err
 		}
 
+		if exitCode != 0 {
+			return &exitError{exitCode, nil}
+		}
+
 		return nil
 	}
 
+	if runFlags.tty && runFlags.noTTY {
+		return errors.New("options --tty and --no-tty cannot be used together")
+	}
+
+	if runFlags.root && cmd.Flag("user").Changed {
+		return errors.New("options --root and --user cannot be used together")
+	}
+
+	var options runOptions
+
+	if runFlags.root {
+		options.user = "root"
+	} else if cmd.Flag("user").Changed {
+		if runFlags.user == "" || strings.ContainsAny(runFlags.user, ":=") {
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "invalid argument for '--user'\n")
+			fmt.Fprintf(&builder, "Use the name or UID of a user in the container.\n")
+			fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+			errMsg := builder.String()
+			return errors.New(errMsg)
+		}
+
+		options.user = runFlags.user
+	}
+
+	if cmd.Flag("workdir").Changed {
+		if !filepath.IsAbs(runFlags.workDir) {
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "invalid argument for '--workdir'\n")
+			fmt.Fprintf(&builder, "The working directory must be an absolute path.\n")
+			fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+			errMsg := builder.String()
+			return errors.New(errMsg)
+		}
+
+		options.workDir = filepath.Clean(runFlags.workDir)
+	}
+
+	for _, envFile := range runFlags.envFiles {
+		env, err := utils.ReadEnvironmentFile(envFile)
+		if err != nil {
+			return err
+		}
+
+		options.env = append(options.env, env...)
+	}
+
+	for _, env := range runFlags.env {
+		if err := utils.ValidateEnvironmentVariable(env); err != nil {
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "invalid argument for '--env'\n")
+			fmt.Fprintf(&builder, "%s\n", capitalizeFirst(err.Error()))
+			fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+			errMsg := builder.String()
+			return errors.New(errMsg)
+		}
+
+		options.env = append(options.env, env)
+	}
+
 	var defaultContainer bool = true
 
 	if runFlags.container != "" {
@@ -135,11 +285,14 @@ func run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	options.tty = runFlags.tty || (!runFlags.noTTY && isStdioTerminal())
+
 	if err := runCommand(container,
 		defaultContainer,
 		image,
 		release,
 		command,
+		options,
 		false,
 		false,
 		true); err != nil {
@@ -153,7 +306,8 @@ func runCommand(container string,
 	defaultContainer bool,
 	image, release string,
 	command []string,
-	emitEscapeSequence, fallbackToBash, pedantic bool) error {
+	options runOptions,
+	emitEscapeSequence, fallbackToBash, pedantic bool) (err error) {
 	if !pedantic {
 		if image == "" {
 			panic("image not specified")
@@ -166,7 +320,7 @@ func runCommand(container string,
 
 	logrus.Debugf("Checking if container %s exists", container)
 
-	if _, err := podman.ContainerExists(container); err != nil {
+	if _, err := engine.ContainerExists(container); err != nil {
 		logrus.Debugf("Container %s not found", container)
 
 		if pedantic {
@@ -203,7 +357,7 @@ func runCommand(container string,
 				return nil
 			}
 
-			if err := createContainer(container, image, release, false); err != nil {
+			if err := createContainer(container, image, release, createOptions{}, false); err != nil {
 				return err
 			}
 		} else if containersCount == 1 && defaultContainer {
@@ -233,71 +387,114 @@ func runCommand(container string,
 		return err
 	}
 
-	entryPoint, entryPointPID, err := getEntryPointAndPID(container)
-	if err != nil {
+	if err := waitForContainerInitialization(container); err != nil {
 		return err
 	}
 
-	if entryPoint != "toolbox" {
-		var builder strings.Builder
-		fmt.Fprintf(&builder, "container %s is too old and no longer supported \n", container)
-		fmt.Fprintf(&builder, "Recreate it with Toolbox version 0.0.17 or newer.\n")
+	if options.user != "" && options.user != currentUser.Username {
+		userPresent, err := isUserPresent(container, options.user)
+		if err != nil {
+			return fmt.Errorf("failed to look for user %s in container %s", options.user, container)
+		}
 
-		errMsg := builder.String()
-		return errors.New(errMsg)
-	}
+		if !userPresent {
+			return fmt.Errorf("user %s not found in container %s", options.user, container)
+		}
 
-	if entryPointPID <= 0 {
-		return fmt.Errorf("invalid entry point PID of container %s", container)
+		homeDir, err := getUserHomeDirectory(container, options.user)
+		if err != nil {
+			return fmt.Errorf("failed to get home directory of user %s in container %s",
+				options.user,
+				container)
+		}
+
+		options.homeDir = homeDir
 	}
 
-	logrus.Debugf("Waiting for container %s to finish initializing", container)
+	recordLastEntered(container)
 
-	toolboxRuntimeDirectory, err := utils.GetRuntimeDirectory(currentUser)
-	if err != nil {
+	if err := runHooksInContainer(container, hookPreEnter); err != nil {
 		return err
 	}
 
-	initializedStamp := fmt.Sprintf("%s/container-initialized-%d", toolboxRuntimeDirectory, entryPointPID)
-
-	logrus.Debugf("Checking if initialization stamp %s exists", initializedStamp)
+	// The post-enter hooks get to clean up after the pre-enter ones
+	// however the command ends. If it failed, that's what is reported.
+	defer func() {
+		if errHooks := runHooksInContainer(container, hookPostEnter); errHooks != nil {
+			if err == nil {
+				err = errHooks
+				return
+			}
 
-	initializedTimeout := 25 // seconds
-	for i := 0; !utils.PathExists(initializedStamp); i++ {
-		if i == initializedTimeout {
-			return fmt.Errorf("failed to initialize container %s", container)
+			fmt.Fprintf(os.Stderr, "Error: %s\n", errHooks)
 		}
+	}()
 
-		time.Sleep(time.Second)
+	if options.session {
+		defer recordSession(container)()
 	}
 
-	logrus.Debugf("Container %s is initialized", container)
-
-	if err := runCommandWithFallbacks(container, command, emitEscapeSequence, fallbackToBash); err != nil {
+	if err := runCommandWithFallbacks(container,
+		command,
+		options,
+		emitEscapeSequence,
+		fallbackToBash); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func runCommandWithFallbacks(container string, command []string, emitEscapeSequence, fallbackToBash bool) error {
+// runCommandWithFallbacks runs a command in a container with 'podman exec',
+// falling back to other commands and working directories if they are
+// missing. A pseudo-terminal is only allocated for the command if asked for.
+// Otherwise, its standard output and error are kept apart, so that they can
+// be redirected separately. If the command fails, an exitError with its
+// status is returned.
+func runCommandWithFallbacks(container string,
+	command []string,
+	options runOptions,
+	emitEscapeSequence, fallbackToBash bool) error {
 	logrus.Debug("Checking if 'podman exec' supports disabling the detach keys")
 
 	var detachKeysSupported bool
 
-	if podman.CheckVersion("1.8.1") {
+	if podman.CheckVersion(engine, "1.8.1") {
 		logrus.Debug("'podman exec' supports disabling the detach keys")
 		detachKeysSupported = true
 	}
 
-	envOptions := utils.GetEnvOptionsForPreservedVariables()
+	envOptions := utils.GetEnvOptionsForPreservedVariables(container)
+	if options.homeDir != "" {
+		envOptions = append(envOptions, fmt.Sprintf("--env=HOME=%s", options.homeDir))
+	}
+
+	for _, env := range options.env {
+		envOptions = append(envOptions, fmt.Sprintf("--env=%s", env))
+	}
+
+	execUser := options.user
+	if execUser == "" {
+		execUser = currentUser.Username
+	}
 
 	runFallbackCommandsIndex := 0
 	runFallbackWorkDirsIndex := 0
 	workDir := workingDirectory
 
+	if options.workDir != "" {
+		runFallbackWorkDirsIndex = len(runFallbackWorkDirs)
+		workDir = options.workDir
+	}
+
 	for {
-		execArgs := constructExecArgs(container, command, detachKeysSupported, envOptions, workDir)
+		execArgs := constructExecArgs(container,
+			command,
+			options.user,
+			detachKeysSupported,
+			options.tty,
+			envOptions,
+			workDir)
 
 		if emitEscapeSequence {
 			fmt.Printf("\033]777;container;push;%s;toolbox;%s\033\\", container, currentUser.Uid)
@@ -305,11 +502,12 @@ func runCommandWithFallbacks(container string, command []string, emitEscapeSeque
 
 		logrus.Debugf("Running in container %s:", container)
 		logrus.Debug("podman")
+		logrus.Debug("exec")
 		for _, arg := range execArgs {
 			logrus.Debugf("%s", arg)
 		}
 
-		exitCode, err := shell.RunWithExitCode("podman", os.Stdin, os.Stdout, nil, execArgs...)
+		exitCode, err := engine.Exec(execArgs, os.Stdin, os.Stdout, os.Stderr)
 
 		if emitEscapeSequence {
 			fmt.Printf("\033]777;container;pop;;;%s\033\\", currentUser.Uid)
@@ -324,9 +522,10 @@ func runCommandWithFallbacks(container string, command []string, emitEscapeSeque
 		case 125:
 			return fmt.Errorf("failed to invoke 'podman exec' in container %s", container)
 		case 126:
-			return fmt.Errorf("failed to invoke command %s in container %s", command[0], container)
+			err := fmt.Errorf("failed to invoke command %s in container %s", command[0], container)
+			return &exitError{runExitCodeCannotInvoke, err}
 		case 127:
-			if pathPresent, _ := isPathPresent(container, workDir); !pathPresent {
+			if pathPresent, _ := isPathPresent(container, execUser, workDir); !pathPresent {
 				if runFallbackWorkDirsIndex < len(runFallbackWorkDirs) {
 					fmt.Fprintf(os.Stderr,
 						"Error: directory %s not found in container %s\n",
@@ -336,6 +535,9 @@ func runCommandWithFallbacks(container string, command []string, emitEscapeSeque
 					workDir = runFallbackWorkDirs[runFallbackWorkDirsIndex]
 					if workDir == "" {
 						workDir = currentUser.HomeDir
+						if options.homeDir != "" {
+							workDir = options.homeDir
+						}
 					}
 
 					fmt.Fprintf(os.Stderr, "Using %s instead.\n", workDir)
@@ -343,7 +545,7 @@ func runCommandWithFallbacks(container string, command []string, emitEscapeSeque
 				} else {
 					return fmt.Errorf("directory %s not found in container %s", workDir, container)
 				}
-			} else if _, err := isCommandPresent(container, command[0]); err != nil {
+			} else if _, err := isCommandPresent(container, execUser, command[0]); err != nil {
 				if fallbackToBash && runFallbackCommandsIndex < len(runFallbackCommands) {
 					fmt.Fprintf(os.Stderr,
 						"Error: command %s not found in container %s\n",
@@ -355,13 +557,18 @@ func runCommandWithFallbacks(container string, command []string, emitEscapeSeque
 
 					runFallbackCommandsIndex++
 				} else {
-					return fmt.Errorf("command %s not found in container %s", command[0], container)
+					err := fmt.Errorf("command %s not found in container %s", command[0], container)
+					return &exitError{runExitCodeNotFound, err}
 				}
 			} else {
-				return nil
+				return &exitError{exitCode, nil}
 			}
 		default:
-			return nil
+			if err != nil {
+				return err
+			}
+
+			return &exitError{exitCode, nil}
 		}
 	}
 }
@@ -390,17 +597,15 @@ func runHelp(cmd *cobra.Command, args []string) {
 func callFlatpakSessionHelper(container string) error {
 	logrus.Debugf("Inspecting mounts of container %s", container)
 
-	info, err := podman.Inspect("container", container)
+	info, err := engine.Inspect("container", container)
 	if err != nil {
 		return fmt.Errorf("failed to inspect entry point of container %s", container)
 	}
 
 	var needsFlatpakSessionHelper bool
 
-	mounts := info["Mounts"].([]interface{})
-	for _, mount := range mounts {
-		destination := mount.(map[string]interface{})["Destination"].(string)
-		if destination == "/run/host/monitor" {
+	for _, mount := range info.Mounts {
+		if mount.Destination == "/run/host/monitor" {
 			logrus.Debug("Requires org.freedesktop.Flatpak.SessionHelper")
 			needsFlatpakSessionHelper = true
 			break
@@ -418,9 +623,14 @@ func callFlatpakSessionHelper(container string) error {
 	return nil
 }
 
+// constructExecArgs returns the arguments for 'podman exec' to run a command
+// in a container as a user, or as the current user if it's empty. Only root,
+// when asked for, keeps the capabilities of the container, which other users
+// don't need and wouldn't have outside it either.
 func constructExecArgs(container string,
 	command []string,
-	detachKeysSupported bool,
+	user string,
+	detachKeysSupported, tty bool,
 	envOptions []string,
 	workDir string) []string {
 	var detachKeys []string
@@ -429,28 +639,46 @@ func constructExecArgs(container string,
 		detachKeys = []string{"--detach-keys", ""}
 	}
 
-	logLevelString := podman.LogLevel.String()
+	var execArgs []string
 
-	execArgs := []string{
-		"--log-level", logLevelString,
-		"exec",
+	execArgs = append(execArgs, detachKeys...)
+
+	execArgs = append(execArgs, "--interactive")
+
+	if tty {
+		execArgs = append(execArgs, "--tty")
 	}
 
-	execArgs = append(execArgs, detachKeys...)
+	dropCapabilities := true
+	var userEnv string
+
+	if user == "" {
+		user = currentUser.Username
+	} else if user == "root" || user == "0" {
+		dropCapabilities = false
+		userEnv = "root"
+	} else if user != currentUser.Username {
+		userEnv = user
+	}
 
 	execArgs = append(execArgs, []string{
-		"--interactive",
-		"--tty",
-		"--user", currentUser.Username,
+		"--user", user,
 		"--workdir", workDir,
 	}...)
 
 	execArgs = append(execArgs, envOptions...)
 
-	execArgs = append(execArgs, []string{
-		container,
-		"capsh", "--caps=", "--", "-c", "exec \"$@\"", "/bin/sh",
-	}...)
+	if userEnv != "" {
+		execArgs = append(execArgs, fmt.Sprintf("--env=USER=%s", userEnv))
+	}
+
+	execArgs = append(execArgs, container)
+
+	if dropCapabilities {
+		execArgs = append(execArgs, []string{
+			"capsh", "--caps=", "--", "-c", "exec \"$@\"", "/bin/sh",
+		}...)
+	}
 
 	execArgs = append(execArgs, command...)
 
@@ -460,75 +688,222 @@ func constructExecArgs(container string,
 func getEntryPointAndPID(container string) (string, int, error) {
 	logrus.Debugf("Inspecting entry point of container %s", container)
 
-	info, err := podman.Inspect("container", container)
+	info, err := engine.Inspect("container", container)
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to inspect entry point of container %s", container)
 	}
 
-	config := info["Config"].(map[string]interface{})
-	entryPoint := config["Cmd"].([]interface{})[0].(string)
+	entryPoint := info.EntryPoint()
+	if entryPoint == "" {
+		return "", 0, fmt.Errorf("failed to inspect entry point of container %s", container)
+	}
 
-	state := info["State"].(map[string]interface{})
-	entryPointPID := state["Pid"]
-	logrus.Debugf("Entry point PID is a %T", entryPointPID)
+	entryPointPID := info.State.Pid
 
-	var entryPointPIDInt int
+	logrus.Debugf("Entry point of container %s is %s (PID=%d)", container, entryPoint, entryPointPID)
 
-	switch entryPointPID := entryPointPID.(type) {
-	case float64:
-		entryPointPIDInt = int(entryPointPID)
-	case int:
-		entryPointPIDInt = entryPointPID
-	default:
-		return "", 0, fmt.Errorf("failed to inspect entry point PID of container %s", container)
+	return entryPoint, entryPointPID, nil
+}
+
+// getLastEnteredStamp returns the file whose modification time is the last
+// time that a container was entered with 'enter' or 'run'.
+func getLastEnteredStamp(container string) (string, error) {
+	stateDirectory, err := utils.GetStateDirectory()
+	if err != nil {
+		return "", err
 	}
 
-	logrus.Debugf("Entry point of container %s is %s (PID=%d)", container, entryPoint, entryPointPIDInt)
+	stamp := filepath.Join(stateDirectory, "last-entered", container)
+	return stamp, nil
+}
+
+// getSessionsDirectory returns the directory with a stamp for each active
+// 'toolbox enter' session of a container, named after the PID of its toolbox
+// process.
+func getSessionsDirectory(container string) (string, error) {
+	toolboxRuntimeDirectory, err := utils.GetRuntimeDirectory(currentUser)
+	if err != nil {
+		return "", err
+	}
 
-	return entryPoint, entryPointPIDInt, nil
+	sessionsDirectory := filepath.Join(toolboxRuntimeDirectory, "sessions", container)
+	return sessionsDirectory, nil
 }
 
-func isCommandPresent(container, command string) (bool, error) {
-	logrus.Debugf("Looking for command %s in container %s", command, container)
+// getUserHomeDirectory returns the home directory of a user in a container
+// from its passwd(5) entry, which can differ from the one on the host.
+func getUserHomeDirectory(container, user string) (string, error) {
+	logrus.Debugf("Looking up home directory of user %s in container %s", user, container)
+
+	var stdout strings.Builder
 
-	logLevelString := podman.LogLevel.String()
 	args := []string{
-		"--log-level", logLevelString,
-		"exec",
 		"--user", currentUser.Username,
 		container,
+		"getent", "passwd", user,
+	}
+
+	exitCode, err := engine.Exec(args, nil, &stdout, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if exitCode != 0 {
+		return "", errors.New("failed to invoke getent(1)")
+	}
+
+	fields := strings.Split(strings.TrimSpace(stdout.String()), ":")
+	if len(fields) != 7 || fields[5] == "" {
+		return "", fmt.Errorf("failed to parse passwd(5) entry of user %s", user)
+	}
+
+	return fields[5], nil
+}
+
+// isStdioTerminal checks if both the standard input and output are
+// terminals, which is when commands run in containers should get a
+// pseudo-terminal of their own.
+func isStdioTerminal() bool {
+	stdinFd := os.Stdin.Fd()
+	stdinFdInt := int(stdinFd)
+
+	stdoutFd := os.Stdout.Fd()
+	stdoutFdInt := int(stdoutFd)
+
+	return term.IsTerminal(stdinFdInt) && term.IsTerminal(stdoutFdInt)
+}
+
+func isCommandPresent(container, user, command string) (bool, error) {
+	logrus.Debugf("Looking for command %s in container %s", command, container)
+
+	args := []string{
+		"--user", user,
+		container,
 		"sh", "-c", "command -v \"$1\"", "sh", command,
 	}
 
-	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+	exitCode, err := engine.Exec(args, nil, nil, nil)
+	if err != nil {
 		return false, err
 	}
 
+	if exitCode != 0 {
+		return false, errors.New("failed to invoke podman(1)")
+	}
+
 	return true, nil
 }
 
-func isPathPresent(container, path string) (bool, error) {
+func isPathPresent(container, user, path string) (bool, error) {
 	logrus.Debugf("Looking for path %s in container %s", path, container)
 
-	logLevelString := podman.LogLevel.String()
 	args := []string{
-		"--log-level", logLevelString,
-		"exec",
-		"--user", currentUser.Username,
+		"--user", user,
 		container,
 		"sh", "-c", "test -d \"$1\"", "sh", path,
 	}
 
-	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+	exitCode, err := engine.Exec(args, nil, nil, nil)
+	if err != nil {
 		return false, err
 	}
 
+	if exitCode != 0 {
+		return false, errors.New("failed to invoke podman(1)")
+	}
+
 	return true, nil
 }
 
+// recordLastEntered updates the stamp read by 'toolbox list'. It's not worth
+// failing over, so errors are only logged.
+func recordLastEntered(container string) {
+	stamp, err := getLastEnteredStamp(container)
+	if err != nil {
+		logrus.Debugf("Recording when container %s was entered failed: %s", container, err)
+		return
+	}
+
+	logrus.Debugf("Recording when container %s was entered in %s", container, stamp)
+
+	if err := os.MkdirAll(filepath.Dir(stamp), 0700); err != nil {
+		logrus.Debugf("Recording when container %s was entered failed: %s", container, err)
+		return
+	}
+
+	if err := ioutil.WriteFile(stamp, nil, 0600); err != nil {
+		logrus.Debugf("Recording when container %s was entered failed: %s", container, err)
+		return
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(stamp, now, now); err != nil {
+		logrus.Debugf("Recording when container %s was entered failed: %s", container, err)
+	}
+}
+
+// recordSession adds a stamp read by 'toolbox list' for a 'toolbox enter'
+// session, which is removed by the returned function when the session ends.
+// It's not worth failing over, so errors are only logged.
+func recordSession(container string) func() {
+	sessionsDirectory, err := getSessionsDirectory(container)
+	if err != nil {
+		logrus.Debugf("Recording session of container %s failed: %s", container, err)
+		return func() {}
+	}
+
+	stamp := filepath.Join(sessionsDirectory, strconv.Itoa(os.Getpid()))
+
+	logrus.Debugf("Recording session of container %s in %s", container, stamp)
+
+	if err := os.MkdirAll(sessionsDirectory, 0700); err != nil {
+		logrus.Debugf("Recording session of container %s failed: %s", container, err)
+		return func() {}
+	}
+
+	if err := ioutil.WriteFile(stamp, nil, 0600); err != nil {
+		logrus.Debugf("Recording session of container %s failed: %s", container, err)
+		return func() {}
+	}
+
+	removeStamp := func() {
+		if err := os.Remove(stamp); err != nil {
+			logrus.Debugf("Removing session stamp %s failed: %s", stamp, err)
+		}
+	}
+
+	return removeStamp
+}
+
+// isUserPresent checks if a user exists in a container. Only the lookup
+// failing, not the user being missing, is an error.
+func isUserPresent(container, user string) (bool, error) {
+	logrus.Debugf("Looking for user %s in container %s", user, container)
+
+	args := []string{
+		"--user", currentUser.Username,
+		container,
+		"sh", "-c", "id \"$1\" >/dev/null 2>&1", "sh", user,
+	}
+
+	exitCode, err := engine.Exec(args, nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	switch exitCode {
+	case 0:
+		return true, nil
+	case 1:
+		return false, nil
+	default:
+		return false, errors.New("failed to invoke podman(1)")
+	}
+}
+
 func startContainer(container string) error {
 	var stderr strings.Builder
-	if err := podman.Start(container, &stderr); err == nil {
+	if err := engine.Start(container, &stderr); err == nil {
 		return nil
 	}
 
@@ -539,7 +914,7 @@ func startContainer(container string) error {
 
 	logrus.Debug("Checking if 'podman system migrate' supports '--new-runtime'")
 
-	if !podman.CheckVersion("1.6.2") {
+	if !podman.CheckVersion(engine, "1.6.2") {
 		var builder strings.Builder
 
 		fmt.Fprintf(&builder,
@@ -562,7 +937,7 @@ func startContainer(container string) error {
 
 	logrus.Debugf("Migrating containers to OCI runtime %s", ociRuntimeRequired)
 
-	if err := podman.SystemMigrate(ociRuntimeRequired); err != nil {
+	if err := engine.SystemMigrate(ociRuntimeRequired); err != nil {
 		var builder strings.Builder
 		fmt.Fprintf(&builder, "failed to migrate containers to OCI runtime %s\n", ociRuntimeRequired)
 		fmt.Fprintf(&builder, "Factory reset with: podman system reset")
@@ -571,7 +946,7 @@ func startContainer(container string) error {
 		return errors.New(errMsg)
 	}
 
-	if err := podman.Start(container, nil); err != nil {
+	if err := engine.Start(container, nil); err != nil {
 		var builder strings.Builder
 		fmt.Fprintf(&builder, "container %s doesn't support cgroups v%d\n", container, cgroupsVersion)
 		fmt.Fprintf(&builder, "Factory reset with: podman system reset")
@@ -582,3 +957,49 @@ func startContainer(container string) error {
 
 	return nil
 }
+
+// waitForContainerInitialization waits until the entry point of a running
+// container has finished initializing it.
+func waitForContainerInitialization(container string) error {
+	entryPoint, entryPointPID, err := getEntryPointAndPID(container)
+	if err != nil {
+		return err
+	}
+
+	if entryPoint != "toolbox" {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "container %s is too old and no longer supported \n", container)
+		fmt.Fprintf(&builder, "Recreate it with Toolbox version 0.0.17 or newer.\n")
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	if entryPointPID <= 0 {
+		return fmt.Errorf("invalid entry point PID of container %s", container)
+	}
+
+	logrus.Debugf("Waiting for container %s to finish initializing", container)
+
+	toolboxRuntimeDirectory, err := utils.GetRuntimeDirectory(currentUser)
+	if err != nil {
+		return err
+	}
+
+	initializedStamp := fmt.Sprintf("%s/container-initialized-%d", toolboxRuntimeDirectory, entryPointPID)
+
+	logrus.Debugf("Checking if initialization stamp %s exists", initializedStamp)
+
+	initializedTimeout := 25 // seconds
+	for i := 0; !utils.PathExists(initializedStamp); i++ {
+		if i == initializedTimeout {
+			return fmt.Errorf("failed to initialize container %s", container)
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	logrus.Debugf("Container %s is initialized", container)
+
+	return nil
+}
@@ -0,0 +1,604 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommandWithFallbacks(t *testing.T) {
+	const container = "fedora-toolbox-35"
+
+	testCases := []struct {
+		name           string
+		command        []string
+		fallbackToBash bool
+		exec           func(workDir, command string) int
+		commands       []string
+		exitCode       int
+		errMsg         string
+	}{
+		{
+			name:     "Success",
+			command:  []string{"ls", "-la"},
+			exec:     func(workDir, command string) int { return 0 },
+			commands: []string{"ls -la"},
+		},
+		{
+			name:    "Command exits with a non-zero code",
+			command: []string{"false"},
+			exec: func(workDir, command string) int {
+				return 1
+			},
+			commands: []string{"false"},
+			exitCode: 1,
+		},
+		{
+			name:     "Command is killed by a signal",
+			command:  []string{"sleep", "infinity"},
+			exec:     func(workDir, command string) int { return 128 + 9 },
+			commands: []string{"sleep infinity"},
+			exitCode: 137,
+		},
+		{
+			name:     "Podman fails (125)",
+			command:  []string{"ls"},
+			exec:     func(workDir, command string) int { return 125 },
+			commands: []string{"ls"},
+			errMsg:   "failed to invoke 'podman exec' in container " + container,
+		},
+		{
+			name:     "Command can't be invoked (126)",
+			command:  []string{"/etc/hosts"},
+			exec:     func(workDir, command string) int { return 126 },
+			commands: []string{"/etc/hosts"},
+			exitCode: 126,
+			errMsg:   "failed to invoke command /etc/hosts in container " + container,
+		},
+		{
+			name:    "Command not found (127)",
+			command: []string{"foo"},
+			exec: func(workDir, command string) int {
+				if strings.HasPrefix(command, "sh -c command -v") {
+					return 1
+				}
+
+				if command == "foo" {
+					return 127
+				}
+
+				return 0
+			},
+			commands: []string{"foo", "sh -c test -d \"$1\" sh " + workingDirectory, "sh -c command -v \"$1\" sh foo"},
+			exitCode: 127,
+			errMsg:   "command foo not found in container " + container,
+		},
+		{
+			name:    "Command exits with 127 on its own",
+			command: []string{"make"},
+			exec: func(workDir, command string) int {
+				if command == "make" {
+					return 127
+				}
+
+				return 0
+			},
+			commands: []string{
+				"make",
+				"sh -c test -d \"$1\" sh " + workingDirectory,
+				"sh -c command -v \"$1\" sh make",
+			},
+			exitCode: 127,
+		},
+		{
+			name:           "Command not found (127); fallback to Bash",
+			command:        []string{"zsh", "-l"},
+			fallbackToBash: true,
+			exec: func(workDir, command string) int {
+				if strings.HasPrefix(command, "sh -c command -v") {
+					return 1
+				}
+
+				if strings.HasSuffix(command, "zsh -l") {
+					return 127
+				}
+
+				return 0
+			},
+			commands: []string{
+				"zsh -l",
+				"sh -c test -d \"$1\" sh " + workingDirectory,
+				"sh -c command -v \"$1\" sh zsh",
+				"/bin/bash -l",
+			},
+		},
+		{
+			name:    "Working directory not found (127); fallback to home",
+			command: []string{"ls"},
+			exec: func(workDir, command string) int {
+				if strings.HasPrefix(command, "sh -c test -d") {
+					return 1
+				}
+
+				if command == "ls" && workDir == workingDirectory {
+					return 127
+				}
+
+				return 0
+			},
+			commands: []string{"ls", "sh -c test -d \"$1\" sh " + workingDirectory, "ls"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, restore := useFakeEngine()
+			defer restore()
+			fake.exec = func(args []string) int {
+				workDir := getExecOption(args, "--workdir")
+				command := execCommand(args, container)
+				command = strings.TrimPrefix(command, "capsh --caps= -- -c exec \"$@\" /bin/sh ")
+				return tc.exec(workDir, command)
+			}
+
+			err := runCommandWithFallbacks(container, tc.command, runOptions{tty: true}, false, tc.fallbackToBash)
+
+			if tc.exitCode != 0 {
+				var errExit *exitError
+				require.True(t, errors.As(err, &errExit))
+				assert.Equal(t, tc.exitCode, errExit.Code)
+				assert.Equal(t, tc.errMsg, errExit.Error())
+			} else if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				assert.False(t, errors.As(err, new(*exitError)))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			var commands []string
+			for _, args := range fake.callsTo("Exec") {
+				command := execCommand(args, container)
+				command = strings.TrimPrefix(command, "capsh --caps= -- -c exec \"$@\" /bin/sh ")
+				commands = append(commands, command)
+			}
+
+			assert.Equal(t, tc.commands, commands)
+		})
+	}
+}
+
+func TestRunCommandWithFallbacksOptions(t *testing.T) {
+	const container = "fedora-toolbox-35"
+
+	t.Run("Working directory and environment", func(t *testing.T) {
+		fake, restore := useFakeEngine()
+		defer restore()
+
+		options := runOptions{
+			env:     []string{"CFLAGS=-O2", "SSH_AUTH_SOCK"},
+			workDir: "/src/project",
+		}
+
+		err := runCommandWithFallbacks(container, []string{"make"}, options, false, false)
+		require.NoError(t, err)
+
+		calls := fake.callsTo("Exec")
+		require.Len(t, calls, 1)
+
+		args := calls[0]
+		assert.Equal(t, "/src/project", getExecOption(args, "--workdir"))
+
+		var envs []string
+		for _, arg := range args {
+			if arg == container {
+				break
+			}
+
+			if strings.HasPrefix(arg, "--env=") {
+				envs = append(envs, arg)
+			}
+		}
+
+		require.True(t, len(envs) >= 2)
+		assert.Equal(t, []string{"--env=CFLAGS=-O2", "--env=SSH_AUTH_SOCK"}, envs[len(envs)-2:])
+	})
+
+	t.Run("Working directory not found (127); no fallback", func(t *testing.T) {
+		fake, restore := useFakeEngine()
+		defer restore()
+		fake.exec = func(args []string) int {
+			command := execCommand(args, container)
+			if strings.HasPrefix(command, "sh -c test -d") {
+				return 1
+			}
+
+			return 127
+		}
+
+		options := runOptions{workDir: "/src/project"}
+
+		err := runCommandWithFallbacks(container, []string{"make"}, options, false, true)
+
+		var errExit *exitError
+		assert.False(t, errors.As(err, &errExit))
+		assert.EqualError(t, err, "directory /src/project not found in container "+container)
+		assert.Len(t, fake.callsTo("Exec"), 2)
+	})
+}
+
+func TestRunCommandHooks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exitCode int
+		errMsg   string
+	}{
+		{
+			name: "Success",
+		},
+		{
+			name:     "Command exits with a non-zero code",
+			exitCode: 1,
+		},
+		{
+			name:     "Podman fails (125)",
+			exitCode: 125,
+			errMsg:   "failed to invoke 'podman exec' in container foo",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stateDirectory, err := ioutil.TempDir("", "toolbox-state")
+			require.NoError(t, err)
+			defer os.RemoveAll(stateDirectory)
+
+			defer setEnv("XDG_STATE_HOME", stateDirectory)()
+
+			userOld := currentUser
+			systemDirectory, _, restoreHooks := setUpHooks(t, []string{"pre-enter/10-a.sh", "post-enter/10-a.sh"}, nil)
+			defer restoreHooks()
+			currentUser.Uid = userOld.Uid
+			currentUser.Gid = userOld.Gid
+
+			preEnter := filepath.Join("/run/host", systemDirectory, "pre-enter", "10-a.sh")
+			postEnter := filepath.Join("/run/host", systemDirectory, "post-enter", "10-a.sh")
+
+			fake, restore := setUpUpgradeTest(t)
+			defer restore()
+			fake.exec = func(args []string) int {
+				if strings.HasSuffix(execCommand(args, "foo"), " ls") {
+					return tc.exitCode
+				}
+
+				return 0
+			}
+
+			err = runCommand("foo", false, "", "", []string{"ls"}, runOptions{}, false, false, true)
+
+			switch {
+			case tc.errMsg != "":
+				assert.EqualError(t, err, tc.errMsg)
+			case tc.exitCode != 0:
+				var errExit *exitError
+				require.True(t, errors.As(err, &errExit))
+				assert.Equal(t, tc.exitCode, errExit.Code)
+			default:
+				assert.NoError(t, err)
+			}
+
+			var hooks []string
+			for _, args := range fake.callsTo("Exec") {
+				if command := execCommand(args, "foo"); command == preEnter || command == postEnter {
+					hooks = append(hooks, command)
+				}
+			}
+
+			assert.Equal(t, []string{preEnter, postEnter}, hooks)
+		})
+	}
+}
+
+func TestRunCommandUser(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exec     func(workDir, command string) int
+		errMsg   string
+		workDir  string
+		homeEnvs []string
+	}{
+		{
+			name: "User not found",
+			exec: func(workDir, command string) int {
+				if strings.HasPrefix(command, "sh -c id") {
+					return 1
+				}
+
+				return 0
+			},
+			errMsg: "user bar not found in container foo",
+		},
+		{
+			name: "Looking for user fails",
+			exec: func(workDir, command string) int {
+				if strings.HasPrefix(command, "sh -c id") {
+					return 125
+				}
+
+				return 0
+			},
+			errMsg: "failed to look for user bar in container foo",
+		},
+		{
+			name: "Looking up home directory fails",
+			exec: func(workDir, command string) int {
+				if strings.HasPrefix(command, "getent passwd") {
+					return 2
+				}
+
+				return 0
+			},
+			errMsg: "failed to get home directory of user bar in container foo",
+		},
+		{
+			name:     "Success",
+			exec:     func(workDir, command string) int { return 0 },
+			workDir:  workingDirectory,
+			homeEnvs: []string{"--env=HOME=/home/bar"},
+		},
+		{
+			name: "Working directory not found (127); fallback to the user's home",
+			exec: func(workDir, command string) int {
+				if strings.HasPrefix(command, "sh -c test -d") {
+					return 1
+				}
+
+				if command == "ls" && workDir == workingDirectory {
+					return 127
+				}
+
+				return 0
+			},
+			workDir:  "/home/bar",
+			homeEnvs: []string{"--env=HOME=/home/bar"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stateDirectory, err := ioutil.TempDir("", "toolbox-state")
+			require.NoError(t, err)
+			defer os.RemoveAll(stateDirectory)
+
+			defer setEnv("XDG_STATE_HOME", stateDirectory)()
+
+			userOld := currentUser
+			_, _, restoreHooks := setUpHooks(t, nil, nil)
+			defer restoreHooks()
+			currentUser.Uid = userOld.Uid
+			currentUser.Gid = userOld.Gid
+
+			fake, restore := setUpUpgradeTest(t)
+			defer restore()
+			fake.exec = func(args []string) int {
+				workDir := getExecOption(args, "--workdir")
+				command := execCommand(args, "foo")
+				command = strings.TrimPrefix(command, "capsh --caps= -- -c exec \"$@\" /bin/sh ")
+				return tc.exec(workDir, command)
+			}
+
+			fake.execOutput = func(args []string) string {
+				if execCommand(args, "foo") == "getent passwd bar" {
+					return "bar:x:1001:1001::/home/bar:/bin/bash\n"
+				}
+
+				return ""
+			}
+
+			options := runOptions{user: "bar"}
+			err = runCommand("foo", false, "", "", []string{"ls"}, options, false, false, true)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+
+			require.NoError(t, err)
+
+			calls := fake.callsTo("Exec")
+			require.NotEmpty(t, calls)
+
+			args := calls[len(calls)-1]
+			assert.True(t, strings.HasSuffix(execCommand(args, "foo"), " ls"))
+			assert.Equal(t, tc.workDir, getExecOption(args, "--workdir"))
+
+			var homeEnvs []string
+			for _, arg := range args {
+				if arg == "foo" {
+					break
+				}
+
+				if strings.HasPrefix(arg, "--env=HOME=") {
+					homeEnvs = append(homeEnvs, arg)
+				}
+			}
+
+			assert.Equal(t, tc.homeEnvs, homeEnvs)
+		})
+	}
+}
+
+func TestRecordSession(t *testing.T) {
+	runtimeDirectory, err := ioutil.TempDir("", "toolbox-runtime")
+	require.NoError(t, err)
+	defer os.RemoveAll(runtimeDirectory)
+
+	defer setEnv("XDG_RUNTIME_DIR", runtimeDirectory)()
+
+	sessionsDirectory, err := getSessionsDirectory("foo")
+	require.NoError(t, err)
+	defer os.RemoveAll(sessionsDirectory)
+
+	stamp := filepath.Join(sessionsDirectory, strconv.Itoa(os.Getpid()))
+
+	removeStamp := recordSession("foo")
+	assert.FileExists(t, stamp)
+
+	container := toolboxContainer{Names: []string{"foo"}, State: "running"}
+	assert.Equal(t, 1, getSessions(container))
+
+	removeStamp()
+	assert.NoFileExists(t, stamp)
+	assert.Equal(t, 0, getSessions(container))
+}
+
+func TestConstructExecArgsTTY(t *testing.T) {
+	const container = "fedora-toolbox-35"
+
+	testCases := []struct {
+		name    string
+		tty     bool
+		options []string
+	}{
+		{
+			name:    "With a pseudo-terminal",
+			tty:     true,
+			options: []string{"--interactive", "--tty"},
+		},
+		{
+			name:    "Without a pseudo-terminal",
+			options: []string{"--interactive"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execArgs := constructExecArgs(container,
+				[]string{"make"},
+				"",
+				false,
+				tc.tty,
+				nil,
+				workingDirectory)
+
+			var options []string
+			for _, arg := range execArgs {
+				if arg == container {
+					break
+				}
+
+				if arg == "--interactive" || arg == "--tty" {
+					options = append(options, arg)
+				}
+			}
+
+			assert.Equal(t, tc.options, options)
+			assert.Equal(t, "make", execArgs[len(execArgs)-1])
+		})
+	}
+}
+
+func TestConstructExecArgsUser(t *testing.T) {
+	const container = "fedora-toolbox-35"
+
+	testCases := []struct {
+		name    string
+		user    string
+		userEnv string
+		command string
+	}{
+		{
+			name:    "Current user",
+			command: "capsh --caps= -- -c exec \"$@\" /bin/sh dnf upgrade",
+		},
+		{
+			name:    "Root",
+			user:    "root",
+			userEnv: "root",
+			command: "dnf upgrade",
+		},
+		{
+			name:    "Root by UID",
+			user:    "0",
+			userEnv: "root",
+			command: "dnf upgrade",
+		},
+		{
+			name:    "Other user",
+			user:    "toolbox-test-user",
+			userEnv: "toolbox-test-user",
+			command: "capsh --caps= -- -c exec \"$@\" /bin/sh dnf upgrade",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execArgs := constructExecArgs(container,
+				[]string{"dnf", "upgrade"},
+				tc.user,
+				false,
+				false,
+				[]string{"--env=USER=" + currentUser.Username},
+				workingDirectory)
+
+			user := currentUser.Username
+			if tc.user != "" {
+				user = tc.user
+			}
+
+			assert.Equal(t, user, getExecOption(execArgs, "--user"))
+			assert.Equal(t, tc.command, execCommand(execArgs, container))
+
+			userEnv := currentUser.Username
+			if tc.userEnv != "" {
+				userEnv = tc.userEnv
+			}
+
+			var userEnvLast string
+			for _, arg := range execArgs {
+				if arg == container {
+					break
+				}
+
+				if strings.HasPrefix(arg, "--env=USER=") {
+					userEnvLast = strings.TrimPrefix(arg, "--env=USER=")
+				}
+			}
+
+			assert.Equal(t, userEnv, userEnvLast)
+		})
+	}
+}
+
+// getExecOption returns the value of an option passed to 'podman exec'.
+func getExecOption(args []string, option string) string {
+	for i, arg := range args {
+		if arg == option && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	return ""
+}
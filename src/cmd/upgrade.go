@@ -0,0 +1,494 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	upgradeFlags struct {
+		image          string
+		release        string
+		replayPackages bool
+	}
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Recreate a toolbox container from a newer image",
+	RunE:  upgrade,
+}
+
+func init() {
+	flags := upgradeCmd.Flags()
+
+	flags.StringVarP(&upgradeFlags.image,
+		"image",
+		"i",
+		"",
+		"Change the name of the base image used to recreate the toolbox container")
+
+	flags.StringVarP(&upgradeFlags.release,
+		"release",
+		"r",
+		"",
+		"Recreate the toolbox container for a different operating system release")
+
+	flags.BoolVar(&upgradeFlags.replayPackages,
+		"replay-packages",
+		false,
+		"Install the packages that were installed in the old toolbox container")
+
+	upgradeCmd.SetHelpFunc(upgradeHelp)
+	rootCmd.AddCommand(upgradeCmd)
+}
+
+func upgrade(cmd *cobra.Command, args []string) error {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			return errors.New("this is not a toolbox container")
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if cmd.Flag("image").Changed && cmd.Flag("release").Changed {
+		return errors.New("options --image and --release cannot be used together")
+	}
+
+	if len(args) != 1 {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "missing argument for \"upgrade\"\n")
+		fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	container := args[0]
+
+	if err := upgradeContainer(container,
+		upgradeFlags.image,
+		upgradeFlags.release,
+		upgradeFlags.replayPackages); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// upgradeContainer replaces a toolbox container with a new one of the same
+// name, created from a newer image with the same options. The old container
+// is kept under a different name until the new one has been initialized, and
+// is restored if anything goes wrong before that.
+func upgradeContainer(container, imageArg, releaseArg string, replayPackages bool) error {
+	logrus.Debug("Checking if 'podman rename' is supported")
+
+	if !podman.CheckVersion(engine, "3.0.0") {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "'podman rename' is needed to upgrade container %s\n", container)
+		fmt.Fprintf(&builder, "Update Podman to version 3.0.0 or newer.")
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	logrus.Debugf("Inspecting container %s", container)
+
+	info, err := engine.Inspect("container", container)
+	if err != nil {
+		err := createErrorContainerNotFound(container)
+		return err
+	}
+
+	if !info.IsToolbox() {
+		return fmt.Errorf("%s is not a toolbox container", container)
+	}
+
+	if info.State.Running {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "container %s is running\n", container)
+		fmt.Fprintf(&builder, "Stop it before upgrading it with: podman stop %s", container)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	backup := container + "-old"
+
+	if exists, _ := engine.ContainerExists(backup); exists {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "container %s already exists\n", backup)
+		fmt.Fprintf(&builder, "It's needed to keep container %s while upgrading it.", container)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	imageOld := info.ImageName
+	if imageOld == "" {
+		imageOld = info.Image
+	}
+
+	image, release, err := getUpgradeImage(container, imageOld, imageArg, releaseArg)
+	if err != nil {
+		return err
+	}
+
+	options := getUpgradeOptions(info)
+
+	logrus.Debugf("Upgrading container %s from image %s to %s (release %s) with options %v",
+		container,
+		imageOld,
+		image,
+		release,
+		getCreateOptionsFromLabels(info.Labels))
+
+	pulled, err := pullImage(image, release)
+	if err != nil {
+		return err
+	}
+
+	if !pulled {
+		return nil
+	}
+
+	imageFull, err := getFullyQualifiedImageFromRepoTags(image)
+	if err != nil {
+		return err
+	}
+
+	// The image is already in local storage, so pullImage didn't look for
+	// a newer one.
+	if image == imageOld {
+		if err := pullImageUpdate(imageFull); err != nil {
+			return err
+		}
+	}
+
+	imageInfo, err := engine.Inspect("image", imageFull)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image %s", imageFull)
+	}
+
+	if imageInfo.ID == info.Image {
+		fmt.Printf("Container %s is already up to date\n", container)
+		return nil
+	}
+
+	if replayPackages {
+		packages, err := getInstalledPackages(container)
+		if err != nil {
+			return err
+		}
+
+		options.packages = packages
+	}
+
+	logrus.Debugf("Renaming container %s to %s", container, backup)
+
+	if err := engine.Rename(container, backup); err != nil {
+		return fmt.Errorf("failed to rename container %s", container)
+	}
+
+	if err := createUpgradedContainer(container, image, release, options); err != nil {
+		restoreUpgradedContainer(container, backup)
+		return err
+	}
+
+	logrus.Debugf("Removing old container %s", backup)
+
+	if err := engine.RemoveContainer(backup, true); err != nil {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "failed to remove old container %s\n", backup)
+		fmt.Fprintf(&builder, "Remove it with: %s rm --force %s", executableBase, backup)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	enterCommand := getEnterCommand(container)
+
+	fmt.Printf("Upgraded container: %s\n", container)
+	fmt.Printf("Enter with: %s\n", enterCommand)
+
+	return nil
+}
+
+// createUpgradedContainer creates the new container, waits until it has been
+// initialized and replays the packages from the old one. Packages that can't
+// be installed don't fail the upgrade, because the new image might not have
+// all of them.
+func createUpgradedContainer(container, image, release string, options createOptions) error {
+	packages := options.packages
+	options.packages = nil
+
+	if err := createContainer(container, image, release, options, false); err != nil {
+		return err
+	}
+
+	if err := startContainer(container); err != nil {
+		return err
+	}
+
+	if err := waitForContainerInitialization(container); err != nil {
+		return err
+	}
+
+	if len(packages) != 0 {
+		replayPackages(container, packages)
+	}
+
+	return nil
+}
+
+// getInstalledPackages returns the packages that were explicitly installed in
+// a container. The container is started, if needed, to ask its package
+// manager.
+func getInstalledPackages(container string) ([]string, error) {
+	logrus.Debugf("Listing installed packages in container %s", container)
+
+	if err := startContainer(container); err != nil {
+		return nil, err
+	}
+
+	// The container was stopped, because running containers can't be
+	// upgraded, and it's left that way for it to be restored if needed.
+	defer func() {
+		logrus.Debugf("Stopping container %s", container)
+
+		if err := engine.Stop(container); err != nil {
+			logrus.Debugf("Stopping container %s failed: %s", container, err)
+		}
+	}()
+
+	if err := waitForContainerInitialization(container); err != nil {
+		return nil, err
+	}
+
+	manager, err := getPackageManager(container)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"--user", "root",
+		container,
+	}
+
+	args = append(args, manager.list...)
+
+	var stdout strings.Builder
+
+	exitCode, err := engine.Exec(args, nil, &stdout, nil)
+	if err != nil || exitCode != 0 {
+		return nil, fmt.Errorf("failed to list installed packages in container %s", container)
+	}
+
+	packages := strings.Fields(stdout.String())
+	logrus.Debugf("Container %s has %d installed packages", container, len(packages))
+
+	return packages, nil
+}
+
+// getUpgradeImage returns the image and release that a container is upgraded
+// to. Without imageArg and releaseArg, that's the image it was created from.
+// With releaseArg, the container must be from one of the supported
+// distributions.
+func getUpgradeImage(container, imageOld, imageArg, releaseArg string) (string, string, error) {
+	if imageArg != "" {
+		image, release, err := utils.ResolveImageName("", imageArg, "")
+		return image, release, err
+	}
+
+	if releaseArg != "" {
+		distro := utils.GetDistroForImage(imageOld)
+		if distro == "" {
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "failed to find the distribution of container %s\n", container)
+			fmt.Fprintf(&builder, "Use '--image' to choose the new image instead.\n")
+			fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+			errMsg := builder.String()
+			return "", "", errors.New(errMsg)
+		}
+
+		release, err := utils.ParseRelease(distro, releaseArg)
+		if err != nil {
+			err := createErrorInvalidRelease()
+			return "", "", err
+		}
+
+		image, release, err := utils.ResolveImageName(distro, "", release)
+		return image, release, err
+	}
+
+	release := utils.ImageReferenceGetTag(imageOld)
+	if release == "" {
+		release = "latest"
+	}
+
+	return imageOld, release, nil
+}
+
+// getUpgradeOptions returns the devices, environment variables and volumes
+// that were given to 'toolbox create' for a container.
+func getUpgradeOptions(info *podman.InspectResult) createOptions {
+	var options createOptions
+
+	createOptions := getCreateOptionsFromLabels(info.Labels)
+	for i := 0; i+1 < len(createOptions); i += 2 {
+		switch createOptions[i] {
+		case "--device":
+			options.devices = append(options.devices, createOptions[i+1])
+		case "--env":
+			options.env = append(options.env, createOptions[i+1])
+		case "--volume":
+			options.volumes = append(options.volumes, createOptions[i+1])
+		}
+	}
+
+	return options
+}
+
+// pullImageUpdate pulls a newer version of an image that is already in local
+// storage. Images that were built locally can't be pulled, and are left
+// alone.
+func pullImageUpdate(imageFull string) error {
+	domain := utils.ImageReferenceGetDomain(imageFull)
+	if domain == "" || domain == "localhost" {
+		return nil
+	}
+
+	logrus.Debugf("Pulling image %s", imageFull)
+
+	stdoutFd := os.Stdout.Fd()
+	stdoutFdInt := int(stdoutFd)
+	if logLevel := logrus.GetLevel(); logLevel < logrus.DebugLevel && term.IsTerminal(stdoutFdInt) {
+		s := spinner.New(spinner.CharSets[9], 500*time.Millisecond)
+		s.Prefix = fmt.Sprintf("Pulling %s: ", imageFull)
+		s.Writer = os.Stdout
+		s.Start()
+		defer s.Stop()
+	}
+
+	if err := engine.Pull(imageFull); err != nil {
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "failed to pull image %s\n", imageFull)
+		fmt.Fprintf(&builder, "If it was a private image, log in with: podman login %s\n", domain)
+		fmt.Fprintf(&builder, "Use '%s --verbose ...' for further details.", executableBase)
+
+		errMsg := builder.String()
+		return errors.New(errMsg)
+	}
+
+	return nil
+}
+
+// replayPackages installs the packages from the old container in the new one.
+// If they can't all be installed together, then they are installed one at a
+// time, and those that fail are reported.
+func replayPackages(container string, packages []string) {
+	s := spinner.New(spinner.CharSets[9], 500*time.Millisecond)
+
+	stdoutFd := os.Stdout.Fd()
+	stdoutFdInt := int(stdoutFd)
+	if logLevel := logrus.GetLevel(); logLevel < logrus.DebugLevel && term.IsTerminal(stdoutFdInt) {
+		s.Prefix = fmt.Sprintf("Installing packages in container %s: ", container)
+		s.Writer = os.Stdout
+		s.Start()
+		defer s.Stop()
+	}
+
+	if err := installPackages(container, packages); err == nil {
+		return
+	}
+
+	logrus.Debugf("Installing packages in container %s one at a time", container)
+
+	var failed []string
+
+	for _, pkg := range packages {
+		if err := installPackages(container, []string{pkg}); err != nil {
+			failed = append(failed, pkg)
+		}
+	}
+
+	// The spinner must be stopped before showing the error below.
+	s.Stop()
+
+	if len(failed) != 0 {
+		fmt.Fprintf(os.Stderr,
+			"Error: failed to install packages in container %s: %s\n",
+			container,
+			strings.Join(failed, ", "))
+	}
+}
+
+// restoreUpgradedContainer gives the old container its name back, after
+// removing whatever was left of the new one.
+func restoreUpgradedContainer(container, backup string) {
+	logrus.Debugf("Restoring container %s from %s", container, backup)
+
+	if exists, _ := engine.ContainerExists(container); exists {
+		if err := engine.RemoveContainer(container, true); err != nil {
+			logrus.Debugf("Removing container %s failed: %s", container, err)
+		}
+	}
+
+	if err := engine.Rename(backup, container); err != nil {
+		logrus.Debugf("Renaming container %s to %s failed: %s", backup, container, err)
+		fmt.Fprintf(os.Stderr, "Error: failed to restore container %s from %s\n", container, backup)
+	}
+}
+
+func upgradeHelp(cmd *cobra.Command, args []string) {
+	if utils.IsInsideContainer() {
+		if !utils.IsInsideToolboxContainer() {
+			fmt.Fprintf(os.Stderr, "Error: this is not a toolbox container\n")
+			return
+		}
+
+		if _, err := utils.ForwardToHost(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return
+		}
+
+		return
+	}
+
+	if err := showManual("toolbox-upgrade"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+}
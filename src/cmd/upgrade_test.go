@@ -0,0 +1,327 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/containers/toolbox/pkg/podman"
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	upgradeTestImageOld = "registry.fedoraproject.org/fedora-toolbox:34"
+	upgradeTestImageNew = "registry.fedoraproject.org/fedora-toolbox:35"
+	upgradeTestPID      = 434343
+)
+
+// setUpUpgradeTest sets up a stopped toolbox container foo created from
+// upgradeTestImageOld, and upgradeTestImageNew in local storage. The
+// container looks initialized as soon as it's started. Everything is undone
+// when the returned function is called at the end of a test.
+func setUpUpgradeTest(t *testing.T) (*fakeEngine, func()) {
+	restoreEnvironment := setUpCreateEnvironment(t)
+
+	toolboxRuntimeDirectory, err := utils.GetRuntimeDirectory(currentUser)
+	if err != nil {
+		restoreEnvironment()
+		require.NoError(t, err)
+	}
+
+	initializedStamp := fmt.Sprintf("%s/container-initialized-%d", toolboxRuntimeDirectory, upgradeTestPID)
+	err = ioutil.WriteFile(initializedStamp, nil, 0644)
+	if err != nil {
+		restoreEnvironment()
+		require.NoError(t, err)
+	}
+
+	fake, restoreEngine := useFakeEngine()
+	fake.containers = []podman.Container{newFakeToolboxContainer("abc", "foo")}
+	fake.images = []podman.Image{
+		{ID: "old", Names: []string{upgradeTestImageOld}},
+		{ID: "new", Names: []string{upgradeTestImageNew}},
+	}
+
+	info := &podman.InspectResult{
+		ID:        "abc",
+		Name:      "foo",
+		Image:     "old",
+		ImageName: upgradeTestImageOld,
+		Labels: map[string]string{
+			"com.github.containers.toolbox":         "true",
+			"com.github.containers.toolbox.devices": `["/dev/kvm"]`,
+			"com.github.containers.toolbox.env":     `["FOO=bar"]`,
+		},
+	}
+
+	info.Config.Cmd = []string{"toolbox", "--log-level", "debug", "init-container"}
+	info.State.Pid = upgradeTestPID
+	fake.inspectResults["container/foo"] = info
+
+	fake.inspectResults["image/"+upgradeTestImageOld] = &podman.InspectResult{ID: "old"}
+	fake.inspectResults["image/"+upgradeTestImageNew] = &podman.InspectResult{ID: "new"}
+	fake.inspectResults["image/fedora-toolbox:35"] = &podman.InspectResult{
+		ID:       "new",
+		RepoTags: []string{upgradeTestImageNew},
+	}
+
+	restore := func() {
+		restoreEngine()
+		os.Remove(initializedStamp)
+		restoreEnvironment()
+	}
+
+	return fake, restore
+}
+
+func TestUpgradeContainer(t *testing.T) {
+	fake, restore := setUpUpgradeTest(t)
+	defer restore()
+
+	err := upgradeContainer("foo", "", "35", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{{"foo", "foo-old"}}, fake.callsTo("Rename"))
+
+	calls := fake.callsTo("Create")
+	require.Len(t, calls, 1)
+
+	createArgsString := strings.Join(calls[0], " ")
+	assert.Contains(t, createArgsString, "--name foo ")
+	assert.Contains(t, createArgsString, " --device /dev/kvm ")
+	assert.Contains(t, createArgsString, " --env FOO=bar ")
+	assert.Contains(t, createArgsString, " "+upgradeTestImageNew+" ")
+
+	assert.Equal(t, [][]string{{"foo"}}, fake.callsTo("Start"))
+	assert.Equal(t, [][]string{{"foo-old", "true"}}, fake.callsTo("RemoveContainer"))
+	assert.Empty(t, fake.callsTo("Pull"))
+}
+
+func TestUpgradeContainerUpToDate(t *testing.T) {
+	fake, restore := setUpUpgradeTest(t)
+	defer restore()
+
+	err := upgradeContainer("foo", "", "", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{{upgradeTestImageOld}}, fake.callsTo("Pull"))
+	assert.Empty(t, fake.callsTo("Rename"))
+	assert.Empty(t, fake.callsTo("Create"))
+	assert.Empty(t, fake.callsTo("RemoveContainer"))
+}
+
+func TestUpgradeContainerReplayPackages(t *testing.T) {
+	fake, restore := setUpUpgradeTest(t)
+	defer restore()
+
+	fake.execOutput = func(args []string) string {
+		command := execCommand(args, "foo")
+
+		switch {
+		case strings.Contains(command, "os-release"):
+			return "NAME=Fedora\nID=fedora\nVERSION_ID=34\n"
+		case strings.HasPrefix(command, "dnf --quiet repoquery"):
+			return "gcc\nmake\n"
+		}
+
+		return ""
+	}
+
+	err := upgradeContainer("foo", "", "35", true)
+	require.NoError(t, err)
+
+	var installs []string
+	for _, args := range fake.callsTo("Exec") {
+		if command := execCommand(args, "foo"); strings.HasPrefix(command, "dnf --assumeyes install") {
+			installs = append(installs, command)
+		}
+	}
+
+	assert.Equal(t, []string{"dnf --assumeyes install gcc make"}, installs)
+	assert.Equal(t, [][]string{{"foo"}}, fake.callsTo("Stop"))
+	assert.Equal(t, [][]string{{"foo-old", "true"}}, fake.callsTo("RemoveContainer"))
+}
+
+func TestUpgradeContainerReplayPackagesFailure(t *testing.T) {
+	fake, restore := setUpUpgradeTest(t)
+	defer restore()
+
+	fake.execOutput = func(args []string) string {
+		command := execCommand(args, "foo")
+
+		switch {
+		case strings.Contains(command, "os-release"):
+			return "NAME=Fedora\nID=fedora\nVERSION_ID=34\n"
+		case strings.HasPrefix(command, "dnf --quiet repoquery"):
+			return "gcc\nmake\n"
+		}
+
+		return ""
+	}
+
+	// The new release doesn't have make anymore.
+	fake.exec = func(args []string) int {
+		if command := execCommand(args, "foo"); strings.Contains(command, "install") && strings.HasSuffix(command, "make") {
+			return 1
+		}
+
+		return 0
+	}
+
+	err := upgradeContainer("foo", "", "35", true)
+	require.NoError(t, err)
+
+	var installs []string
+	for _, args := range fake.callsTo("Exec") {
+		if command := execCommand(args, "foo"); strings.HasPrefix(command, "dnf --assumeyes install") {
+			installs = append(installs, command)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"dnf --assumeyes install gcc make",
+		"dnf --assumeyes install gcc",
+		"dnf --assumeyes install make",
+	}, installs)
+
+	assert.Equal(t, [][]string{{"foo", "foo-old"}}, fake.callsTo("Rename"))
+	assert.Equal(t, [][]string{{"foo-old", "true"}}, fake.callsTo("RemoveContainer"))
+}
+
+func TestUpgradeContainerReplayPackagesListFailure(t *testing.T) {
+	fake, restore := setUpUpgradeTest(t)
+	defer restore()
+
+	fake.exec = func(args []string) int {
+		if command := execCommand(args, "foo"); strings.HasPrefix(command, "dnf --quiet repoquery") {
+			return 1
+		}
+
+		return 0
+	}
+
+	fake.execOutput = func(args []string) string {
+		if command := execCommand(args, "foo"); strings.Contains(command, "os-release") {
+			return "NAME=Fedora\nID=fedora\nVERSION_ID=34\n"
+		}
+
+		return ""
+	}
+
+	err := upgradeContainer("foo", "", "35", true)
+	assert.EqualError(t, err, "failed to list installed packages in container foo")
+
+	assert.Equal(t, [][]string{{"foo"}}, fake.callsTo("Stop"))
+	assert.Empty(t, fake.callsTo("Rename"))
+}
+
+func TestUpgradeContainerFailure(t *testing.T) {
+	fake, restore := setUpUpgradeTest(t)
+	defer restore()
+	fake.errors["Create/"] = errors.New("podman create failed")
+
+	err := upgradeContainer("foo", "", "35", false)
+	assert.EqualError(t, err, "failed to create container foo")
+
+	assert.Equal(t, [][]string{{"foo", "foo-old"}, {"foo-old", "foo"}}, fake.callsTo("Rename"))
+	assert.Empty(t, fake.callsTo("RemoveContainer"))
+}
+
+func TestUpgradeContainerRunning(t *testing.T) {
+	fake, restore := setUpUpgradeTest(t)
+	defer restore()
+	fake.inspectResults["container/foo"].State.Running = true
+
+	err := upgradeContainer("foo", "", "35", false)
+	assert.EqualError(t, err, "container foo is running\nStop it before upgrading it with: podman stop foo")
+	assert.Empty(t, fake.callsTo("Rename"))
+}
+
+func TestGetUpgradeImage(t *testing.T) {
+	testCases := []struct {
+		name       string
+		imageOld   string
+		imageArg   string
+		releaseArg string
+		image      string
+		release    string
+		errMsg     string
+	}{
+		{
+			name:     "Same image",
+			imageOld: upgradeTestImageOld,
+			image:    upgradeTestImageOld,
+			release:  "34",
+		},
+		{
+			name:     "Same image without tag",
+			imageOld: "localhost/custom",
+			image:    "localhost/custom",
+			release:  "latest",
+		},
+		{
+			name:     "Different image",
+			imageOld: upgradeTestImageOld,
+			imageArg: "localhost/custom:1",
+			image:    "localhost/custom:1",
+			release:  "1",
+		},
+		{
+			name:       "Different release",
+			imageOld:   upgradeTestImageOld,
+			releaseArg: "f35",
+			image:      "fedora-toolbox:35",
+			release:    "35",
+		},
+		{
+			name:       "Invalid release",
+			imageOld:   upgradeTestImageOld,
+			releaseArg: "foo",
+			errMsg:     "invalid argument for '--release'\nRun '" + executableBase + " --help' for usage.",
+		},
+		{
+			name:       "Unknown distribution",
+			imageOld:   "localhost/custom:1",
+			releaseArg: "2",
+			errMsg: "failed to find the distribution of container foo\n" +
+				"Use '--image' to choose the new image instead.\n" +
+				"Run '" + executableBase + " --help' for usage.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			image, release, err := getUpgradeImage("foo", tc.imageOld, tc.imageArg, tc.releaseArg)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.image, image)
+			assert.Equal(t, tc.release, release)
+		})
+	}
+}
@@ -59,6 +59,28 @@ func askForConfirmation(prompt string) bool {
 	return retVal
 }
 
+// capitalizeFirst turns an error message into a sentence for the lines that
+// follow the first line of a multi-line error.
+func capitalizeFirst(s string) string {
+	if s == "" {
+		return s
+	}
+
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func createErrorContainerExists(container string) error {
+	enterCommand := getEnterCommand(container)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "container %s already exists\n", container)
+	fmt.Fprintf(&builder, "Enter with: %s\n", enterCommand)
+	fmt.Fprintf(&builder, "Run '%s --help' for usage.", executableBase)
+
+	errMsg := builder.String()
+	return errors.New(errMsg)
+}
+
 func createErrorContainerNotFound(container string) error {
 	var builder strings.Builder
 	fmt.Fprintf(&builder, "container %s not found\n", container)
@@ -78,6 +100,26 @@ func createErrorInvalidRelease() error {
 	return errors.New(errMsg)
 }
 
+// exitError makes toolbox exit with a particular status. The error, if any,
+// is shown like any other, while a nil error means that there is nothing to
+// show, like when a command run by toolbox failed on its own.
+type exitError struct {
+	Code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return ""
+	}
+
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
 func getUsageForCommonCommands() string {
 	var builder strings.Builder
 	fmt.Fprintf(&builder, "create    Create a new toolbox container\n")
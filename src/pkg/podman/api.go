@@ -0,0 +1,294 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// apiEngine talks to the Podman service over its UNIX socket using the libpod
+// REST API, which avoids forking podman(1) for every operation.
+//
+// Operations that need a terminal, show progress or rewrite Podman's storage
+// (create, exec, pull and system migrate) are still delegated to podman(1).
+type apiEngine struct {
+	cliEngine
+
+	client *http.Client
+	socket string
+}
+
+// apiError is the body of an unsuccessful response from the libpod REST API.
+type apiError struct {
+	Cause   string `json:"cause"`
+	Message string `json:"message"`
+}
+
+const (
+	apiTimeout = 30 * time.Second
+)
+
+// NewAPIEngine returns an Engine that talks to the Podman service listening
+// on the UNIX socket at the given path.
+func NewAPIEngine(socket string) Engine {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	client := &http.Client{
+		Timeout:   apiTimeout,
+		Transport: transport,
+	}
+
+	return &apiEngine{client: client, socket: socket}
+}
+
+func (e *apiEngine) ContainerExists(container string) (bool, error) {
+	path := "/libpod/containers/" + url.PathEscape(container) + "/exists"
+	if _, err := e.request(http.MethodGet, path, nil, nil); err != nil {
+		logrus.Debugf("Checking if container %s exists failed: %s", container, err)
+		return false, fmt.Errorf("failed to find container %s", container)
+	}
+
+	return true, nil
+}
+
+func (e *apiEngine) GetVersion() (string, error) {
+	if e.version != "" {
+		return e.version, nil
+	}
+
+	var body []byte
+	if _, err := e.request(http.MethodGet, "/libpod/version", nil, &body); err != nil {
+		return "", err
+	}
+
+	versionString, err := parseVersion(body)
+	if err != nil {
+		return "", err
+	}
+
+	e.version = versionString
+	return e.version, nil
+}
+
+func (e *apiEngine) ImageExists(image string) (bool, error) {
+	path := "/libpod/images/" + url.PathEscape(image) + "/exists"
+	if _, err := e.request(http.MethodGet, path, nil, nil); err != nil {
+		logrus.Debugf("Checking if image %s exists failed: %s", image, err)
+		return false, fmt.Errorf("failed to find image %s", image)
+	}
+
+	return true, nil
+}
+
+func (e *apiEngine) Inspect(typearg string, target string) (*InspectResult, error) {
+	var path string
+
+	switch typearg {
+	case "container":
+		path = "/libpod/containers/" + url.PathEscape(target) + "/json"
+	case "image":
+		path = "/libpod/images/" + url.PathEscape(target) + "/json"
+	default:
+		panicMsg := fmt.Sprintf("unknown type %s", typearg)
+		panic(panicMsg)
+	}
+
+	var body []byte
+	if _, err := e.request(http.MethodGet, path, nil, &body); err != nil {
+		return nil, err
+	}
+
+	var info InspectResult
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (e *apiEngine) ListContainers() ([]Container, error) {
+	query := url.Values{}
+	query.Set("all", "true")
+
+	var body []byte
+	if _, err := e.request(http.MethodGet, "/libpod/containers/json", query, &body); err != nil {
+		return nil, err
+	}
+
+	containers, err := parseContainers(body)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(containers, func(i, j int) bool {
+		return firstName(containers[i].Names) < firstName(containers[j].Names)
+	})
+
+	return containers, nil
+}
+
+func (e *apiEngine) ListImages() ([]Image, error) {
+	var body []byte
+	if _, err := e.request(http.MethodGet, "/libpod/images/json", nil, &body); err != nil {
+		return nil, err
+	}
+
+	images, err := parseImages(body)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(images, func(i, j int) bool {
+		return firstName(images[i].Names) < firstName(images[j].Names)
+	})
+
+	return images, nil
+}
+
+func (e *apiEngine) RemoveContainer(container string, forceDelete bool) error {
+	logrus.Debugf("Removing container %s", container)
+
+	query := url.Values{}
+	query.Set("force", strconv.FormatBool(forceDelete))
+
+	path := "/libpod/containers/" + url.PathEscape(container)
+	statusCode, err := e.request(http.MethodDelete, path, query, nil)
+	switch statusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("container %s does not exist", container)
+	case http.StatusConflict:
+		return fmt.Errorf("container %s is running", container)
+	}
+
+	logrus.Debugf("Removing container %s failed: %s", container, err)
+	return fmt.Errorf("failed to remove container %s", container)
+}
+
+func (e *apiEngine) RemoveImage(image string, forceDelete bool) error {
+	logrus.Debugf("Removing image %s", image)
+
+	query := url.Values{}
+	query.Set("force", strconv.FormatBool(forceDelete))
+
+	path := "/libpod/images/" + url.PathEscape(image)
+	statusCode, err := e.request(http.MethodDelete, path, query, nil)
+	switch statusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("image %s does not exist", image)
+	case http.StatusConflict:
+		return fmt.Errorf("image %s has dependent children", image)
+	}
+
+	logrus.Debugf("Removing image %s failed: %s", image, err)
+	return fmt.Errorf("failed to remove image %s", image)
+}
+
+func (e *apiEngine) Start(container string, stderr io.Writer) error {
+	path := "/libpod/containers/" + url.PathEscape(container) + "/start"
+	statusCode, err := e.request(http.MethodPost, path, nil, nil)
+
+	// The container is already running.
+	if statusCode == http.StatusNotModified {
+		return nil
+	}
+
+	if err != nil && stderr != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+	}
+
+	return err
+}
+
+// request sends a request to the libpod REST API and returns the HTTP status
+// code of the response. The body of a successful response is stored in body,
+// if it's not nil. An unsuccessful response is converted into an error.
+func (e *apiEngine) request(method, path string, query url.Values, body *[]byte) (int, error) {
+	address := "http://d" + path
+	if len(query) != 0 {
+		address = address + "?" + query.Encode()
+	}
+
+	logrus.Debugf("Sending request to the Podman service: %s %s", method, address)
+
+	request, err := http.NewRequest(method, address, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request %s %s: %w", method, path, err)
+	}
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to the Podman service at %s: %w", e.socket, err)
+	}
+
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return response.StatusCode, fmt.Errorf("failed to read response to %s %s: %w", method, path, err)
+	}
+
+	if response.StatusCode >= http.StatusBadRequest || response.StatusCode == http.StatusNotModified {
+		var apiErr apiError
+		if err := json.Unmarshal(data, &apiErr); err != nil || apiErr.Message == "" {
+			return response.StatusCode, fmt.Errorf("%s %s failed with status %d",
+				method,
+				path,
+				response.StatusCode)
+		}
+
+		return response.StatusCode, errors.New(apiErr.Message)
+	}
+
+	if body != nil {
+		*body = data
+	}
+
+	return response.StatusCode, nil
+}
+
+// firstName returns the first of the names of a container or image, or an
+// empty string if there are none. It's kept here, rather than shared with the
+// cmd package, so that this package doesn't depend on pkg/utils.
+func firstName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	return names[0]
+}
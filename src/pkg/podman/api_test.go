@@ -0,0 +1,146 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package podman
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startStubService serves handler on a UNIX socket in a temporary directory,
+// and returns an Engine connected to it. The returned function stops the
+// service at the end of a test.
+func startStubService(t *testing.T, handler http.Handler) (Engine, func()) {
+	dir, err := ioutil.TempDir("", "toolbox-podman-api")
+	require.NoError(t, err)
+
+	socket := filepath.Join(dir, "podman.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		require.NoError(t, err)
+	}
+
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+
+	stop := func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+
+	return NewAPIEngine(socket), stop
+}
+
+func TestAPIEngine(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/libpod/version", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Version": "3.4.2", "ApiVersion": "3.4.2"}`)
+	})
+
+	mux.HandleFunc("/libpod/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("all"))
+		fmt.Fprint(w, `[
+			{"Id": "2", "Names": ["zoo"], "State": "exited", "Created": "2020-09-13T12:26:40Z"},
+			{"Id": "1", "Names": ["foo"], "State": "running", "Created": "2020-09-13T12:26:40Z",
+			 "Labels": {"com.github.containers.toolbox": "true"}}
+		]`)
+	})
+
+	mux.HandleFunc("/libpod/containers/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/libpod/containers/foo/exists":
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/libpod/containers/foo/json":
+			fmt.Fprint(w, `{"Id": "1", "Config": {"Cmd": ["toolbox"]}, "State": {"Pid": 42}}`)
+		case r.URL.Path == "/libpod/containers/foo/start":
+			assert.Equal(t, http.MethodPost, r.Method)
+			w.WriteHeader(http.StatusNotModified)
+		case r.URL.Path == "/libpod/containers/foo" && r.Method == http.MethodDelete:
+			assert.Equal(t, "false", r.URL.Query().Get("force"))
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"cause": "container state improper", "message": "container is running"}`)
+		case strings.HasSuffix(r.URL.Path, "/start"):
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"message": "use system migrate to mitigate"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "no such container"}`)
+		}
+	})
+
+	engine, stop := startStubService(t, mux)
+	defer stop()
+
+	version, err := engine.GetVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "3.4.2", version)
+	assert.True(t, CheckVersion(engine, "2.0.0"))
+
+	containers, err := engine.ListContainers()
+	assert.NoError(t, err)
+	require.Len(t, containers, 2)
+	assert.Equal(t, []string{"foo"}, containers[0].Names)
+	assert.Equal(t, "running", containers[0].Status)
+	assert.Equal(t, int64(1600000000), containers[0].Created.Unix())
+
+	exists, err := engine.ContainerExists("foo")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = engine.ContainerExists("bar")
+	assert.EqualError(t, err, "failed to find container bar")
+	assert.False(t, exists)
+
+	info, err := engine.Inspect("container", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "toolbox", info.EntryPoint())
+	assert.Equal(t, 42, info.State.Pid)
+
+	_, err = engine.Inspect("container", "bar")
+	assert.EqualError(t, err, "no such container")
+
+	assert.NoError(t, engine.Start("foo", nil))
+
+	var stderr strings.Builder
+	assert.Error(t, engine.Start("bar", &stderr))
+	assert.Contains(t, stderr.String(), "use system migrate to mitigate")
+
+	assert.EqualError(t, engine.RemoveContainer("foo", false), "container foo is running")
+	assert.EqualError(t, engine.RemoveContainer("bar", true), "container bar does not exist")
+}
+
+func TestAPIEngineNoService(t *testing.T) {
+	engine := NewAPIEngine("/nonexistent/podman.sock")
+
+	_, err := engine.GetVersion()
+	assert.Error(t, err)
+
+	exists, err := engine.ImageExists("fedora-toolbox:35")
+	assert.Error(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package podman
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Container holds the information about a container that is listed by
+// 'podman ps --format json'.
+//
+// The JSON emitted by Podman changed considerably between versions 1.x, 2.x
+// and 3.x, and the differences are normalized here.
+type Container struct {
+	ID      string
+	Names   []string
+	Status  string
+	Image   string
+	ImageID string
+	Labels  map[string]string
+
+	// Created is the time when the container was created. It's the zero
+	// time.Time if Podman only provided a human-readable string, which is
+	// then stored in CreatedRelative.
+	Created         time.Time
+	CreatedRelative string
+}
+
+func (c *Container) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID      string
+		Names   interface{}
+		Status  string
+		State   interface{}
+		Created interface{}
+		Image   string
+		ImageID string
+		Labels  map[string]string
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	c.ID = raw.ID
+
+	// In Podman V1 the field 'Names' held a single string but since Podman V2 the
+	// field holds an array of strings
+	c.Names = nil
+
+	switch value := raw.Names.(type) {
+	case nil:
+	case string:
+		c.Names = append(c.Names, value)
+	case []interface{}:
+		for _, v := range value {
+			name, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("unexpected type %T in the names of container %s", v, raw.ID)
+			}
+
+			c.Names = append(c.Names, name)
+		}
+	default:
+		return fmt.Errorf("unexpected type %T for the names of container %s", value, raw.ID)
+	}
+
+	// In Podman V1 the field holding a string about the container's state was
+	// called 'Status' and field 'State' held a number representing the state. In
+	// Podman V2 the string was moved to 'State' and field 'Status' was dropped.
+	switch value := raw.State.(type) {
+	case string:
+		c.Status = value
+	default:
+		c.Status = raw.Status
+	}
+
+	// In Podman V1 the field 'Created' held a human-readable string in format
+	// "5 minutes ago". Since Podman V2 the field holds an integer with Unix time.
+	// After a discussion in https://github.com/containers/podman/issues/6594 the
+	// previous value was moved to field 'CreatedAt'. The libpod REST API
+	// uses a RFC 3339 time stamp instead.
+	created, createdRelative, err := parseCreated(raw.Created)
+	if err != nil {
+		return fmt.Errorf("failed to parse creation time of container %s: %w", raw.ID, err)
+	}
+
+	c.Created = created
+	c.CreatedRelative = createdRelative
+	c.Image = raw.Image
+	c.ImageID = raw.ImageID
+	c.Labels = raw.Labels
+
+	return nil
+}
+
+// parseContainers parses a JSON array of containers. A container that can't
+// be parsed is logged and skipped, so that it doesn't hide the others.
+func parseContainers(data []byte) ([]Container, error) {
+	var containersRaw []json.RawMessage
+	if err := json.Unmarshal(data, &containersRaw); err != nil {
+		return nil, err
+	}
+
+	var containers []Container
+
+	for _, containerRaw := range containersRaw {
+		var container Container
+		if err := json.Unmarshal(containerRaw, &container); err != nil {
+			logrus.Errorf("failed to unmarshal container: %v", err)
+			continue
+		}
+
+		containers = append(containers, container)
+	}
+
+	return containers, nil
+}
+
+// parseCreated interprets the different representations of a creation time
+// used by Podman. Go interprets numbers in JSON as float64.
+func parseCreated(value interface{}) (time.Time, string, error) {
+	switch value := value.(type) {
+	case nil:
+		return time.Time{}, "", nil
+	case float64:
+		return time.Unix(int64(value), 0), "", nil
+	case string:
+		if created, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return created, "", nil
+		}
+
+		return time.Time{}, value, nil
+	}
+
+	return time.Time{}, "", fmt.Errorf("unexpected type %T", value)
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package podman
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/HarryMichal/go-version"
+)
+
+// Engine is the container engine used to manage toolbox containers and images.
+//
+// The default implementation returned by NewCLIEngine invokes podman(1), but
+// any other implementation with the same semantics can be used instead.
+type Engine interface {
+	// Build builds an image. Parameter args holds the same arguments as
+	// those taken by 'podman build'.
+	Build(args []string, stdout, stderr io.Writer) error
+
+	// Commit creates an image from a container. Parameter args holds the
+	// same arguments as those taken by 'podman commit'.
+	Commit(args []string) error
+
+	// ContainerExists checks if a container with given ID/name exists.
+	ContainerExists(container string) (bool, error)
+
+	// Create creates a container. Parameter args holds the same
+	// arguments as those taken by 'podman create'.
+	Create(args []string) error
+
+	// Exec runs a command inside a running container. Parameter args
+	// holds the same arguments as those taken by 'podman exec'. Returns
+	// the exit code of the command.
+	Exec(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+
+	// GetVersion returns the version of Podman.
+	GetVersion() (string, error)
+
+	// ImageExists checks if an image with given ID/name exists.
+	ImageExists(image string) (bool, error)
+
+	// Inspect returns the details of a container or an image. Parameter
+	// typearg takes in values 'container' or 'image'.
+	Inspect(typearg string, target string) (*InspectResult, error)
+
+	// InspectContainers returns the details of several containers at once.
+	// If size is true, the disk usage of their writable layers is included
+	// too, which is slower.
+	InspectContainers(containers []string, size bool) ([]InspectResult, error)
+
+	// ListContainers returns all containers sorted by their names.
+	ListContainers() ([]Container, error)
+
+	// ListImages returns all images sorted by their repositories.
+	ListImages() ([]Image, error)
+
+	// Load loads the images in an archive created by Save.
+	Load(input string) error
+
+	// Pull pulls an image.
+	Pull(imageName string) error
+
+	RemoveContainer(container string, forceDelete bool) error
+
+	RemoveImage(image string, forceDelete bool) error
+
+	// Rename changes the name of a container. It needs Podman 3.0.0 or
+	// newer.
+	Rename(container, name string) error
+
+	// Run runs a command in a new container. Parameter args holds the
+	// same arguments as those taken by 'podman run'. Returns the exit code
+	// of the command.
+	Run(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+
+	// Save writes an image, with its names, to an archive.
+	Save(image, output string) error
+
+	// Start starts a container. The error output of the engine is
+	// written to stderr, if it's not nil.
+	Start(container string, stderr io.Writer) error
+
+	// Stop stops a running container.
+	Stop(container string) error
+
+	// Tag adds a name to an image.
+	Tag(image, name string) error
+
+	// SystemMigrate migrates the containers to a newer version of
+	// Podman, and optionally to a different OCI runtime.
+	SystemMigrate(ociRuntimeRequired string) error
+}
+
+// CheckVersion compares provided version with the version of Podman.
+//
+// Takes in one string parameter that should be in the format that is used for versioning (eg. 1.0.0, 2.5.1-dev).
+//
+// Returns true if the current version is equal to or higher than the required version.
+func CheckVersion(engine Engine, requiredVersion string) bool {
+	currentVersion, _ := engine.GetVersion()
+
+	currentVersion = version.Normalize(currentVersion)
+	requiredVersion = version.Normalize(requiredVersion)
+
+	return version.CompareSimple(currentVersion, requiredVersion) >= 0
+}
+
+func IsToolboxContainer(engine Engine, container string) (bool, error) {
+	info, err := engine.Inspect("container", container)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect container %s", container)
+	}
+
+	if !info.IsToolbox() {
+		return false, fmt.Errorf("%s is not a toolbox container", container)
+	}
+
+	return true, nil
+}
+
+func IsToolboxImage(engine Engine, image string) (bool, error) {
+	info, err := engine.Inspect("image", image)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect image %s", image)
+	}
+
+	if !info.IsToolbox() {
+		return false, fmt.Errorf("%s is not a toolbox image", image)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package podman
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Image holds the information about an image that is listed by
+// 'podman images --format json'.
+type Image struct {
+	ID          string
+	Names       []string
+	Digest      string
+	RepoDigests []string
+	Labels      map[string]string
+
+	// Size is the disk usage of the image in bytes, or 0 if unknown.
+	Size int64
+
+	// Created is the time when the image was created. It's the zero
+	// time.Time if Podman only provided a human-readable string, which is
+	// then stored in CreatedRelative.
+	Created         time.Time
+	CreatedRelative string
+}
+
+func (i *Image) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID          string
+		Names       []string
+		RepoTags    []string
+		Digest      string
+		RepoDigests []string
+		Created     interface{}
+		Labels      map[string]string
+		Size        interface{}
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	i.ID = raw.ID
+
+	// The libpod REST API doesn't always fill in 'Names', but 'RepoTags'
+	// carries the same information.
+	i.Names = raw.Names
+	if len(i.Names) == 0 {
+		i.Names = raw.RepoTags
+	}
+
+	i.Digest = raw.Digest
+	i.RepoDigests = raw.RepoDigests
+
+	// Until Podman 2.0.x the field 'Created' held a human-readable string in
+	// format "5 minutes ago". Since Podman 2.1 the field holds an integer with
+	// Unix time.
+	created, createdRelative, err := parseCreated(raw.Created)
+	if err != nil {
+		return fmt.Errorf("failed to parse creation time of image %s: %w", raw.ID, err)
+	}
+
+	i.Created = created
+	i.CreatedRelative = createdRelative
+	i.Labels = raw.Labels
+
+	// Go interprets numbers in JSON as float64. Anything else is left as
+	// unknown.
+	if size, ok := raw.Size.(float64); ok {
+		i.Size = int64(size)
+	}
+
+	return nil
+}
+
+// parseImages parses a JSON array of images. An image that can't be parsed is
+// logged and skipped, so that it doesn't hide the others.
+func parseImages(data []byte) ([]Image, error) {
+	var imagesRaw []json.RawMessage
+	if err := json.Unmarshal(data, &imagesRaw); err != nil {
+		return nil, err
+	}
+
+	var images []Image
+
+	for _, imageRaw := range imagesRaw {
+		var image Image
+		if err := json.Unmarshal(imageRaw, &image); err != nil {
+			logrus.Errorf("failed to unmarshal toolbox image: %v", err)
+			continue
+		}
+
+		images = append(images, image)
+	}
+
+	return images, nil
+}
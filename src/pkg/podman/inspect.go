@@ -0,0 +1,99 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package podman
+
+import (
+	"encoding/json"
+)
+
+type Mount struct {
+	Type        string
+	Source      string
+	Destination string
+	Options     []string
+	RW          bool
+}
+
+// InspectResult holds the subset of the output of 'podman inspect' that is
+// used by Toolbox, for both containers and images.
+type InspectResult struct {
+	ID        string
+	Name      string
+	Image     string
+	ImageName string
+
+	Config struct {
+		Cmd    []string
+		Env    []string
+		Labels map[string]string
+	}
+
+	State struct {
+		Status  string
+		Running bool
+		Pid     int
+	}
+
+	// SizeRw is the disk usage of the writable layer of a container. It's
+	// only set if it was asked for.
+	SizeRw int64
+
+	Mounts      []Mount
+	RepoTags    []string
+	RepoDigests []string
+	Digest      string
+
+	// Labels holds the labels of both containers and images. Podman
+	// reports the labels of an image at the top level, and the labels of
+	// a container inside Config.
+	Labels map[string]string
+}
+
+func (r *InspectResult) UnmarshalJSON(data []byte) error {
+	// The alias prevents infinite recursion through this method.
+	type inspectResult InspectResult
+
+	var raw inspectResult
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*r = InspectResult(raw)
+
+	if r.Labels == nil {
+		r.Labels = r.Config.Labels
+	}
+
+	return nil
+}
+
+// EntryPoint returns the command that the container was created to run, which
+// is 'toolbox' for all supported toolbox containers.
+func (r *InspectResult) EntryPoint() string {
+	if len(r.Config.Cmd) == 0 {
+		return ""
+	}
+
+	return r.Config.Cmd[0]
+}
+
+// IsToolbox checks if the labels mark the container or image as compatible
+// with Toolbox.
+func (r *InspectResult) IsToolbox() bool {
+	return r.Labels["com.github.containers.toolbox"] == "true" ||
+		r.Labels["com.github.debarshiray.toolbox"] == "true"
+}
@@ -19,40 +19,33 @@ package podman
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 
-	"github.com/HarryMichal/go-version"
 	"github.com/containers/toolbox/pkg/shell"
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	podmanVersion string
-)
+// cliEngine is the default Engine, which invokes the podman(1) binary for
+// every operation.
+type cliEngine struct {
+	version string
+}
 
 var (
 	LogLevel = logrus.ErrorLevel
 )
 
-// CheckVersion compares provided version with the version of Podman.
-//
-// Takes in one string parameter that should be in the format that is used for versioning (eg. 1.0.0, 2.5.1-dev).
-//
-// Returns true if the current version is equal to or higher than the required version.
-func CheckVersion(requiredVersion string) bool {
-	currentVersion, _ := GetVersion()
-
-	currentVersion = version.Normalize(currentVersion)
-	requiredVersion = version.Normalize(requiredVersion)
-
-	return version.CompareSimple(currentVersion, requiredVersion) >= 0
+// NewCLIEngine returns an Engine that invokes the podman(1) binary.
+func NewCLIEngine() Engine {
+	return &cliEngine{}
 }
 
 // ContainerExists checks using Podman if a container with given ID/name exists.
 //
 // Parameter container is a name or an id of a container.
-func ContainerExists(container string) (bool, error) {
+func (e *cliEngine) ContainerExists(container string) (bool, error) {
 	logLevelString := LogLevel.String()
 	args := []string{"--log-level", logLevelString, "container", "exists", container}
 
@@ -68,63 +61,102 @@ func ContainerExists(container string) (bool, error) {
 	return true, nil
 }
 
-// GetContainers is a wrapper function around `podman ps --format json` command.
-//
-// Parameter args accepts an array of strings to be passed to the wrapped command (eg. ["-a", "--filter", "123"]).
-//
-// Returned value is a slice of dynamically unmarshalled json, so it needs to be treated properly.
+func (e *cliEngine) Build(args []string, stdout, stderr io.Writer) error {
+	logLevelString := LogLevel.String()
+	args = append([]string{"--log-level", logLevelString, "build"}, args...)
+
+	if err := shell.Run("podman", nil, stdout, stderr, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *cliEngine) Commit(args []string) error {
+	logLevelString := LogLevel.String()
+	args = append([]string{"--log-level", logLevelString, "commit"}, args...)
+
+	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListContainers is a wrapper function around `podman ps --all --format json` command.
 //
 // If a problem happens during execution, first argument is nil and second argument holds the error message.
-func GetContainers(args ...string) ([]map[string]interface{}, error) {
+func (e *cliEngine) ListContainers() ([]Container, error) {
 	var stdout bytes.Buffer
 
 	logLevelString := LogLevel.String()
-	args = append([]string{"--log-level", logLevelString, "ps", "--format", "json"}, args...)
+	args := []string{"--log-level", logLevelString, "ps", "--all", "--format", "json", "--sort", "names"}
 
 	if err := shell.Run("podman", nil, &stdout, nil, args...); err != nil {
 		return nil, err
 	}
 
 	output := stdout.Bytes()
-	var containers []map[string]interface{}
 
-	if err := json.Unmarshal(output, &containers); err != nil {
+	containers, err := parseContainers(output)
+	if err != nil {
 		return nil, err
 	}
 
 	return containers, nil
 }
 
-// GetImages is a wrapper function around `podman images --format json` command.
-//
-// Parameter args accepts an array of strings to be passed to the wrapped command (eg. ["-a", "--filter", "123"]).
-//
-// Returned value is a slice of dynamically unmarshalled json, so it needs to be treated properly.
+// ListImages is a wrapper function around `podman images --format json` command.
 //
 // If a problem happens during execution, first argument is nil and second argument holds the error message.
-func GetImages(args ...string) ([]map[string]interface{}, error) {
+func (e *cliEngine) ListImages() ([]Image, error) {
 	var stdout bytes.Buffer
 
 	logLevelString := LogLevel.String()
-	args = append([]string{"--log-level", logLevelString, "images", "--format", "json"}, args...)
+	args := []string{"--log-level", logLevelString, "images", "--format", "json", "--sort", "repository"}
 	if err := shell.Run("podman", nil, &stdout, nil, args...); err != nil {
 		return nil, err
 	}
 
 	output := stdout.Bytes()
-	var images []map[string]interface{}
 
-	if err := json.Unmarshal(output, &images); err != nil {
+	images, err := parseImages(output)
+	if err != nil {
 		return nil, err
 	}
 
 	return images, nil
 }
 
+// Create is a wrapper around the 'podman create' command
+//
+// Parameter args holds the options, the image and the command of the container.
+func (e *cliEngine) Create(args []string) error {
+	logLevelString := LogLevel.String()
+	args = append([]string{"--log-level", logLevelString, "create"}, args...)
+
+	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Exec is a wrapper around the 'podman exec' command
+//
+// Parameter args holds the options, the container and the command to run. The
+// exit code of the command is returned.
+func (e *cliEngine) Exec(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	logLevelString := LogLevel.String()
+	args = append([]string{"--log-level", logLevelString, "exec"}, args...)
+
+	return shell.RunWithExitCode("podman", stdin, stdout, stderr, args...)
+}
+
 // GetVersion returns version of Podman in a string
-func GetVersion() (string, error) {
-	if podmanVersion != "" {
-		return podmanVersion, nil
+func (e *cliEngine) GetVersion() (string, error) {
+	if e.version != "" {
+		return e.version, nil
 	}
 
 	var stdout bytes.Buffer
@@ -136,26 +168,19 @@ func GetVersion() (string, error) {
 		return "", err
 	}
 
-	output := stdout.Bytes()
-	var jsonoutput map[string]interface{}
-	if err := json.Unmarshal(output, &jsonoutput); err != nil {
+	versionString, err := parseVersion(stdout.Bytes())
+	if err != nil {
 		return "", err
 	}
 
-	podmanClientInfoInterface := jsonoutput["Client"]
-	switch podmanClientInfo := podmanClientInfoInterface.(type) {
-	case nil:
-		podmanVersion = jsonoutput["Version"].(string)
-	case map[string]interface{}:
-		podmanVersion = podmanClientInfo["Version"].(string)
-	}
-	return podmanVersion, nil
+	e.version = versionString
+	return e.version, nil
 }
 
 // ImageExists checks using Podman if an image with given ID/name exists.
 //
 // Parameter image is a name or an id of an image.
-func ImageExists(image string) (bool, error) {
+func (e *cliEngine) ImageExists(image string) (bool, error) {
 	logLevelString := LogLevel.String()
 	args := []string{"--log-level", logLevelString, "image", "exists", image}
 
@@ -174,7 +199,7 @@ func ImageExists(image string) (bool, error) {
 // Inspect is a wrapper around 'podman inspect' command
 //
 // Parameter 'typearg' takes in values 'container' or 'image' that is passed to the --type flag
-func Inspect(typearg string, target string) (map[string]interface{}, error) {
+func (e *cliEngine) Inspect(typearg string, target string) (*InspectResult, error) {
 	var stdout bytes.Buffer
 
 	logLevelString := LogLevel.String()
@@ -185,49 +210,64 @@ func Inspect(typearg string, target string) (map[string]interface{}, error) {
 	}
 
 	output := stdout.Bytes()
-	var info []map[string]interface{}
+	var info []InspectResult
 
 	if err := json.Unmarshal(output, &info); err != nil {
 		return nil, err
 	}
 
-	return info[0], nil
+	if len(info) == 0 {
+		return nil, fmt.Errorf("failed to find %s %s", typearg, target)
+	}
+
+	return &info[0], nil
 }
 
-func IsToolboxContainer(container string) (bool, error) {
-	info, err := Inspect("container", container)
-	if err != nil {
-		return false, fmt.Errorf("failed to inspect container %s", container)
+// InspectContainers is a wrapper around 'podman inspect' for several
+// containers, so that only one 'podman' process is needed.
+func (e *cliEngine) InspectContainers(containers []string, size bool) ([]InspectResult, error) {
+	if len(containers) == 0 {
+		return nil, nil
 	}
 
-	labels, _ := info["Config"].(map[string]interface{})["Labels"].(map[string]interface{})
-	if labels["com.github.containers.toolbox"] != "true" && labels["com.github.debarshiray.toolbox"] != "true" {
-		return false, fmt.Errorf("%s is not a toolbox container", container)
+	var stdout bytes.Buffer
+
+	logLevelString := LogLevel.String()
+	args := []string{"--log-level", logLevelString, "inspect", "--format", "json", "--type", "container"}
+
+	if size {
+		args = append(args, "--size")
 	}
 
-	return true, nil
-}
+	args = append(args, containers...)
 
-func IsToolboxImage(image string) (bool, error) {
-	info, err := Inspect("image", image)
-	if err != nil {
-		return false, fmt.Errorf("failed to inspect image %s", image)
+	if err := shell.Run("podman", nil, &stdout, nil, args...); err != nil {
+		return nil, err
 	}
 
-	if info["Labels"] == nil {
-		return false, fmt.Errorf("%s is not a toolbox image", image)
+	output := stdout.Bytes()
+	var info []InspectResult
+
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, err
 	}
 
-	labels := info["Labels"].(map[string]interface{})
-	if labels["com.github.containers.toolbox"] != "true" && labels["com.github.debarshiray.toolbox"] != "true" {
-		return false, fmt.Errorf("%s is not a toolbox image", image)
+	return info, nil
+}
+
+func (e *cliEngine) Load(input string) error {
+	logLevelString := LogLevel.String()
+	args := []string{"--log-level", logLevelString, "load", "--input", input}
+
+	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+		return err
 	}
 
-	return true, nil
+	return nil
 }
 
 // Pull pulls an image
-func Pull(imageName string) error {
+func (e *cliEngine) Pull(imageName string) error {
 	logLevelString := LogLevel.String()
 	args := []string{"--log-level", logLevelString, "pull", imageName}
 
@@ -238,7 +278,7 @@ func Pull(imageName string) error {
 	return nil
 }
 
-func RemoveContainer(container string, forceDelete bool) error {
+func (e *cliEngine) RemoveContainer(container string, forceDelete bool) error {
 	logrus.Debugf("Removing container %s", container)
 
 	logLevelString := LogLevel.String()
@@ -271,7 +311,7 @@ func RemoveContainer(container string, forceDelete bool) error {
 	return nil
 }
 
-func RemoveImage(image string, forceDelete bool) error {
+func (e *cliEngine) RemoveImage(image string, forceDelete bool) error {
 	logrus.Debugf("Removing image %s", image)
 
 	logLevelString := LogLevel.String()
@@ -304,11 +344,67 @@ func RemoveImage(image string, forceDelete bool) error {
 	return nil
 }
 
+// parseVersion extracts the version of Podman from the output of 'podman
+// version --format json'. Podman V1 reported it at the top level, while later
+// versions split it into 'Client' and 'Server'.
+func parseVersion(data []byte) (string, error) {
+	var raw struct {
+		Version string
+		Client  *struct {
+			Version string
+		}
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return "", err
+	}
+
+	versionString := raw.Version
+	if raw.Client != nil {
+		versionString = raw.Client.Version
+	}
+
+	if versionString == "" {
+		return "", errors.New("failed to find the Podman version")
+	}
+
+	return versionString, nil
+}
+
 func SetLogLevel(logLevel logrus.Level) {
 	LogLevel = logLevel
 }
 
-func Start(container string, stderr io.Writer) error {
+func (e *cliEngine) Rename(container, name string) error {
+	logLevelString := LogLevel.String()
+	args := []string{"--log-level", logLevelString, "rename", container, name}
+
+	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *cliEngine) Run(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	logLevelString := LogLevel.String()
+	args = append([]string{"--log-level", logLevelString, "run"}, args...)
+
+	return shell.RunWithExitCode("podman", stdin, stdout, stderr, args...)
+}
+
+func (e *cliEngine) Save(image, output string) error {
+	logLevelString := LogLevel.String()
+	args := []string{"--log-level", logLevelString, "save", "--output", output, image}
+
+	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *cliEngine) Start(container string, stderr io.Writer) error {
 	logLevelString := LogLevel.String()
 	args := []string{"--log-level", logLevelString, "start", container}
 
@@ -319,7 +415,29 @@ func Start(container string, stderr io.Writer) error {
 	return nil
 }
 
-func SystemMigrate(ociRuntimeRequired string) error {
+func (e *cliEngine) Stop(container string) error {
+	logLevelString := LogLevel.String()
+	args := []string{"--log-level", logLevelString, "stop", container}
+
+	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *cliEngine) Tag(image, name string) error {
+	logLevelString := LogLevel.String()
+	args := []string{"--log-level", logLevelString, "tag", image, name}
+
+	if err := shell.Run("podman", nil, nil, nil, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *cliEngine) SystemMigrate(ociRuntimeRequired string) error {
 	logLevelString := LogLevel.String()
 	args := []string{"--log-level", logLevelString, "system", "migrate"}
 	if ociRuntimeRequired != "" {
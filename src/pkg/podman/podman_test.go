@@ -0,0 +1,216 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package podman
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerUnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name            string
+		data            string
+		names           []string
+		status          string
+		created         time.Time
+		createdRelative string
+		ok              bool
+	}{
+		{
+			name:            "Podman V1",
+			data:            `{"ID": "abc", "Names": "foo", "Status": "running", "State": 3, "Created": "5 minutes ago"}`,
+			names:           []string{"foo"},
+			status:          "running",
+			createdRelative: "5 minutes ago",
+			ok:              true,
+		},
+		{
+			name:    "Podman V2",
+			data:    `{"Id": "abc", "Names": ["foo"], "State": "exited", "Created": 1600000000}`,
+			names:   []string{"foo"},
+			status:  "exited",
+			created: time.Unix(1600000000, 0),
+			ok:      true,
+		},
+		{
+			name:    "libpod REST API",
+			data:    `{"Id": "abc", "Names": ["foo"], "State": "running", "Created": "2020-09-13T12:26:40Z"}`,
+			names:   []string{"foo"},
+			status:  "running",
+			created: time.Unix(1600000000, 0),
+			ok:      true,
+		},
+		{
+			name: "Invalid names",
+			data: `{"Id": "abc", "Names": [42], "State": "running"}`,
+			ok:   false,
+		},
+		{
+			name: "Invalid creation time",
+			data: `{"Id": "abc", "Names": ["foo"], "Created": true}`,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var container Container
+			err := json.Unmarshal([]byte(tc.data), &container)
+
+			if !tc.ok {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "abc", container.ID)
+			assert.Equal(t, tc.names, container.Names)
+			assert.Equal(t, tc.status, container.Status)
+			assert.True(t, tc.created.Equal(container.Created))
+			assert.Equal(t, tc.createdRelative, container.CreatedRelative)
+		})
+	}
+}
+
+func TestImageUnmarshalJSON(t *testing.T) {
+	data := `{"Id": "abc", "RepoTags": ["localhost/foo:latest"], "Created": 1600000000,
+		"Labels": {"com.github.containers.toolbox": "true"}, "Size": 123456789}`
+
+	var image Image
+	err := json.Unmarshal([]byte(data), &image)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"localhost/foo:latest"}, image.Names)
+	assert.True(t, time.Unix(1600000000, 0).Equal(image.Created))
+	assert.Equal(t, "true", image.Labels["com.github.containers.toolbox"])
+	assert.Equal(t, int64(123456789), image.Size)
+}
+
+func TestInspectResultUnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name       string
+		data       string
+		entryPoint string
+		pid        int
+		isToolbox  bool
+	}{
+		{
+			name: "Container",
+			data: `{"Id": "abc",
+				"Config": {"Cmd": ["toolbox", "init-container"],
+					   "Labels": {"com.github.containers.toolbox": "true"}},
+				"State": {"Pid": 1234},
+				"Mounts": [{"Destination": "/run/host/monitor"}]}`,
+			entryPoint: "toolbox",
+			pid:        1234,
+			isToolbox:  true,
+		},
+		{
+			name:      "Image",
+			data:      `{"Id": "abc", "Labels": {"com.github.debarshiray.toolbox": "true"}}`,
+			isToolbox: true,
+		},
+		{
+			name:      "Image without labels",
+			data:      `{"Id": "abc", "Labels": null}`,
+			isToolbox: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var info InspectResult
+			err := json.Unmarshal([]byte(tc.data), &info)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.entryPoint, info.EntryPoint())
+			assert.Equal(t, tc.pid, info.State.Pid)
+			assert.Equal(t, tc.isToolbox, info.IsToolbox())
+		})
+	}
+}
+
+func TestParseContainers(t *testing.T) {
+	data := `[{"ID": "abc", "Names": ["foo"], "Created": 1600000000},
+		{"ID": "def", "Names": ["bar"], "Created": true},
+		{"ID": "ghi", "Names": ["baz"], "Created": 1600000000}]`
+
+	containers, err := parseContainers([]byte(data))
+	assert.NoError(t, err)
+	assert.Len(t, containers, 2)
+	assert.Equal(t, "abc", containers[0].ID)
+	assert.Equal(t, "ghi", containers[1].ID)
+
+	_, err = parseContainers([]byte(`{"ID": "abc"}`))
+	assert.Error(t, err)
+}
+
+func TestParseImages(t *testing.T) {
+	data := `[{"Id": "abc", "Names": ["localhost/foo:latest"], "Created": 1600000000},
+		{"Id": "def", "Names": ["localhost/bar:latest"], "Created": ["yesterday"]}]`
+
+	images, err := parseImages([]byte(data))
+	assert.NoError(t, err)
+	assert.Len(t, images, 1)
+	assert.Equal(t, "abc", images[0].ID)
+
+	_, err = parseImages([]byte(`not JSON`))
+	assert.Error(t, err)
+}
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		version string
+		ok      bool
+	}{
+		{
+			name:    "Podman V1",
+			data:    `{"Version": "1.9.3"}`,
+			version: "1.9.3",
+			ok:      true,
+		},
+		{
+			name:    "Podman V2",
+			data:    `{"Client": {"Version": "2.2.1"}, "Server": {"Version": "2.2.1"}}`,
+			version: "2.2.1",
+			ok:      true,
+		},
+		{
+			name: "Missing version",
+			data: `{"Client": {}}`,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			version, err := parseVersion([]byte(tc.data))
+
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+
+			assert.Equal(t, tc.version, version)
+		})
+	}
+}
@@ -0,0 +1,295 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package registry looks up images in container registries through the
+// Docker Registry HTTP API V2, which is also implemented by OCI registries.
+// Only anonymous access is supported, which is enough for the public images
+// that toolbox containers are usually created from.
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/containers/toolbox/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// Client talks to container registries.
+type Client struct {
+	client *http.Client
+}
+
+const (
+	dockerHubDomain    = "docker.io"
+	dockerHubAPIDomain = "registry-1.docker.io"
+
+	registryTimeout = 30 * time.Second
+)
+
+var (
+	// manifestMediaTypes are the kinds of manifests that are asked for.
+	// Manifest lists and image indexes come first, because that's what
+	// Podman records the digest of when pulling multi-architecture images.
+	manifestMediaTypes = []string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}
+)
+
+// NewClient returns a Client that uses an HTTP client, or a default one if
+// it's nil.
+func NewClient(client *http.Client) *Client {
+	if client == nil {
+		client = &http.Client{Timeout: registryTimeout}
+	}
+
+	return &Client{client: client}
+}
+
+// GetDigest returns the digest of the manifest that a registry currently has
+// for an image, which must be a fully qualified name, like
+// registry.fedoraproject.org/fedora-toolbox:35. A missing tag means 'latest'.
+func (c *Client) GetDigest(image string) (string, error) {
+	domain, repository, tag, err := parseImage(image)
+	if err != nil {
+		return "", err
+	}
+
+	address := fmt.Sprintf("https://%s/v2/%s/manifests/%s", domain, repository, tag)
+
+	logrus.Debugf("Looking up digest of image %s at %s", image, address)
+
+	response, err := c.request(http.MethodHead, address, "")
+	if err != nil {
+		return "", err
+	}
+
+	response.Body.Close()
+
+	if response.StatusCode == http.StatusUnauthorized {
+		token, err := c.getToken(response.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			logrus.Debugf("Getting a token for image %s failed: %s", image, err)
+			return "", fmt.Errorf("failed to authenticate with registry %s", domain)
+		}
+
+		response, err = c.request(http.MethodHead, address, token)
+		if err != nil {
+			return "", err
+		}
+
+		response.Body.Close()
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", fmt.Errorf("image %s requires logging in to registry %s", image, domain)
+	case http.StatusNotFound:
+		return "", fmt.Errorf("image %s not found in registry %s", image, domain)
+	default:
+		return "", fmt.Errorf("registry %s failed with status %d", domain, response.StatusCode)
+	}
+
+	digest := response.Header.Get("Docker-Content-Digest")
+	if digest != "" {
+		return digest, nil
+	}
+
+	// Not all registries report the digest for HEAD requests, so the
+	// manifest is downloaded to calculate it.
+	logrus.Debugf("Registry %s didn't report the digest of image %s", domain, image)
+
+	token := strings.TrimPrefix(response.Request.Header.Get("Authorization"), "Bearer ")
+
+	response, err = c.request(http.MethodGet, address, token)
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry %s failed with status %d", domain, response.StatusCode)
+	}
+
+	manifest, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifest of image %s: %w", image, err)
+	}
+
+	digest = fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	return digest, nil
+}
+
+// getToken gets an anonymous bearer token as described by a
+// WWW-Authenticate header.
+func (c *Client) getToken(authenticate string) (string, error) {
+	if !strings.HasPrefix(authenticate, "Bearer ") {
+		return "", fmt.Errorf("unsupported authentication scheme in '%s'", authenticate)
+	}
+
+	parameters := parseAuthenticateParameters(strings.TrimPrefix(authenticate, "Bearer "))
+
+	realm := parameters["realm"]
+	if realm == "" {
+		return "", errors.New("missing realm")
+	}
+
+	query := url.Values{}
+	for _, key := range []string{"scope", "service"} {
+		if value := parameters[key]; value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	address := realm
+	if len(query) != 0 {
+		address = address + "?" + query.Encode()
+	}
+
+	response, err := c.client.Get(address)
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s failed with status %d", realm, response.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse response from %s: %w", realm, err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("missing token in response from %s", realm)
+}
+
+func (c *Client) request(method, address, token string) (*http.Response, error) {
+	request, err := http.NewRequest(method, address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request %s %s: %w", method, address, err)
+	}
+
+	request.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		logrus.Debugf("Sending request %s %s failed: %s", method, address, err)
+
+		domain := request.URL.Host
+		return nil, fmt.Errorf("failed to connect to registry %s", domain)
+	}
+
+	return response, nil
+}
+
+// parseAuthenticateParameters parses the comma-separated KEY="VALUE" pairs
+// of a WWW-Authenticate header.
+func parseAuthenticateParameters(parameters string) map[string]string {
+	result := make(map[string]string)
+
+	for parameters != "" {
+		i := strings.IndexRune(parameters, '=')
+		if i == -1 {
+			break
+		}
+
+		key := strings.TrimSpace(parameters[:i])
+		parameters = parameters[i+1:]
+
+		var value string
+
+		if strings.HasPrefix(parameters, "\"") {
+			j := strings.IndexRune(parameters[1:], '"')
+			if j == -1 {
+				value, parameters = parameters[1:], ""
+			} else {
+				value, parameters = parameters[1:j+1], parameters[j+2:]
+			}
+		} else if j := strings.IndexRune(parameters, ','); j != -1 {
+			value, parameters = parameters[:j], parameters[j:]
+		} else {
+			value, parameters = parameters, ""
+		}
+
+		result[key] = value
+		parameters = strings.TrimPrefix(strings.TrimSpace(parameters), ",")
+	}
+
+	return result
+}
+
+// parseImage splits a fully qualified image name into the domain of the
+// API of its registry, its repository and its tag.
+func parseImage(image string) (string, string, string, error) {
+	domain := utils.ImageReferenceGetDomain(image)
+	if domain == "" {
+		return "", "", "", fmt.Errorf("image %s has no registry", image)
+	}
+
+	if domain == "localhost" {
+		return "", "", "", fmt.Errorf("image %s is only in local storage", image)
+	}
+
+	remainder := image[len(domain)+1:]
+
+	if i := strings.IndexRune(remainder, '@'); i != -1 {
+		remainder = remainder[:i]
+	}
+
+	repository, tag := remainder, "latest"
+	if i := strings.LastIndex(remainder, ":"); i != -1 {
+		repository, tag = remainder[:i], remainder[i+1:]
+	}
+
+	if domain == dockerHubDomain {
+		domain = dockerHubAPIDomain
+
+		if !strings.ContainsRune(repository, '/') {
+			repository = "library/" + repository
+		}
+	}
+
+	return domain, repository, tag, nil
+}
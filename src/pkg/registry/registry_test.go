@@ -0,0 +1,128 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package registry
+
+import (
+	"testing"
+
+	"github.com/containers/toolbox/pkg/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDigest(t *testing.T) {
+	testCases := []struct {
+		name       string
+		token      string
+		omitDigest bool
+	}{
+		{name: "Anonymous"},
+		{name: "Token", token: "secret"},
+		{name: "Without Docker-Content-Digest", omitDigest: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := registrytest.NewServer()
+			defer server.Close()
+
+			server.Token = tc.token
+			server.OmitDigest = tc.omitDigest
+			server.SetManifest("fedora/fedora-toolbox", "35", `{"schemaVersion": 2}`)
+
+			client := NewClient(server.Client())
+
+			digest, err := client.GetDigest(server.Domain() + "/fedora/fedora-toolbox:35")
+			require.NoError(t, err)
+			assert.Equal(t, registrytest.Digest(`{"schemaVersion": 2}`), digest)
+
+			_, err = client.GetDigest(server.Domain() + "/fedora/fedora-toolbox:36")
+			assert.EqualError(t, err, "image "+server.Domain()+"/fedora/fedora-toolbox:36 not found in registry "+
+				server.Domain())
+		})
+	}
+}
+
+func TestParseImage(t *testing.T) {
+	testCases := []struct {
+		image      string
+		domain     string
+		repository string
+		tag        string
+		errMsg     string
+	}{
+		{
+			image:      "registry.fedoraproject.org/fedora-toolbox:35",
+			domain:     "registry.fedoraproject.org",
+			repository: "fedora-toolbox",
+			tag:        "35",
+		},
+		{
+			image:      "quay.io/toolbx/arch-toolbox",
+			domain:     "quay.io",
+			repository: "toolbx/arch-toolbox",
+			tag:        "latest",
+		},
+		{
+			image:      "localhost:5000/custom:1@sha256:0123",
+			domain:     "localhost:5000",
+			repository: "custom",
+			tag:        "1",
+		},
+		{
+			image:      "docker.io/ubuntu:22.04",
+			domain:     "registry-1.docker.io",
+			repository: "library/ubuntu",
+			tag:        "22.04",
+		},
+		{
+			image:  "fedora-toolbox:35",
+			errMsg: "image fedora-toolbox:35 has no registry",
+		},
+		{
+			image:  "localhost/custom:latest",
+			errMsg: "image localhost/custom:latest is only in local storage",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			domain, repository, tag, err := parseImage(tc.image)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.domain, domain)
+			assert.Equal(t, tc.repository, repository)
+			assert.Equal(t, tc.tag, tag)
+		})
+	}
+}
+
+func TestParseAuthenticateParameters(t *testing.T) {
+	parameters := parseAuthenticateParameters(
+		`realm="https://auth.example.com/token", service=registry.example.com,scope="repository:a/b:pull,push"`)
+
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:a/b:pull,push",
+	}, parameters)
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package registrytest provides a stand-in for a container registry, so that
+// looking up images can be tested without the network.
+package registrytest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Server is a local registry that serves the digests of manifests over TLS.
+// Its Client trusts the certificate of the server.
+type Server struct {
+	*httptest.Server
+
+	// Token, if set, is required as a bearer token, which is handed out
+	// anonymously like by public registries.
+	Token string
+
+	// OmitDigest makes the server leave out the Docker-Content-Digest
+	// header, like some registries do.
+	OmitDigest bool
+
+	lock      sync.Mutex
+	manifests map[string]string
+}
+
+// NewServer starts a Server without any manifests. It must be closed with
+// Close.
+func NewServer() *Server {
+	server := &Server{manifests: make(map[string]string)}
+	server.Server = httptest.NewTLSServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// Digest returns the digest of a manifest, as reported by registries.
+func Digest(manifest string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest)))
+}
+
+// Domain returns the domain of the registry to be used in image names, like
+// 127.0.0.1:43210.
+func (s *Server) Domain() string {
+	return s.Listener.Addr().String()
+}
+
+// Manifest returns the manifest served for REPOSITORY:TAG, or an empty
+// string if there is none.
+func (s *Server) Manifest(repository, tag string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.manifests[repository+":"+tag]
+}
+
+// SetManifest makes the server serve manifest for REPOSITORY:TAG. Its digest
+// is given by Digest.
+func (s *Server) SetManifest(repository, tag, manifest string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.manifests[repository+":"+tag] = manifest
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"token": s.Token})
+		return
+	}
+
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		w.Header().Set("WWW-Authenticate",
+			fmt.Sprintf(`Bearer realm="%s/token",service="registrytest",scope="repository:pull"`, s.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	i := strings.LastIndex(path, "/manifests/")
+	if path == r.URL.Path || i == -1 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	repository := path[:i]
+	tag := path[i+len("/manifests/"):]
+
+	manifest := s.Manifest(repository, tag)
+	if manifest == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	if !s.OmitDigest {
+		w.Header().Set("Docker-Content-Digest", Digest(manifest))
+	}
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", fmt.Sprint(len(manifest)))
+		return
+	}
+
+	fmt.Fprint(w, manifest)
+}
@@ -22,6 +22,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"syscall"
 
 	"github.com/sirupsen/logrus"
 )
@@ -55,6 +56,14 @@ func RunWithExitCode(name string, stdin io.Reader, stdout, stderr io.Writer, arg
 
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
+			// Like shells do, a process that was killed by a signal is
+			// reported as 128 plus the number of the signal, instead of
+			// the -1 from ExitCode.
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				exitCode := 128 + int(status.Signal())
+				return exitCode, nil
+			}
+
 			exitCode := exitErr.ExitCode()
 			return exitCode, nil
 		}
@@ -201,6 +201,22 @@ func TestShellRunWithExitCode(t *testing.T) {
 				stderr: []byte("cat: /bogus/file.foo: No such file or directory\n"),
 			},
 		},
+		{
+			name: "FAIL_Killed_By_Signal",
+			input: input{
+				commandName: "sh",
+				stdIn:       os.Stdin,
+				args:        []string{"-c", "kill -TERM $$"},
+				loglevel:    logrus.InfoLevel,
+				useStdErr:   false,
+			},
+			expect: expect{
+				err:    nil,
+				code:   143,
+				stdout: nil,
+				stderr: nil,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
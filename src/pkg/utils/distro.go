@@ -0,0 +1,406 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	distroNameRegexp = "^[a-z0-9][a-z0-9_.-]*$"
+
+	// releaseFormatCodename is for releases that are either positive
+	// integers or code names, eg., Debian's 12 or bookworm.
+	releaseFormatCodename = "codename"
+
+	// releaseFormatInteger is for releases that are positive integers,
+	// eg., Fedora's 34.
+	releaseFormatInteger = "integer"
+
+	// releaseFormatMajorMinor is for releases that are positive numbers
+	// with a point release, eg., RHEL's 8.4.
+	releaseFormatMajorMinor = "major.minor"
+
+	// releaseFormatRolling is for distributions without releases, eg.,
+	// Arch Linux. Their images are always tagged as releaseRolling.
+	releaseFormatRolling = "rolling"
+
+	// releaseFormatYearMonth is for releases that are named after the year
+	// and month they were made in, eg., Ubuntu's 22.04.
+	releaseFormatYearMonth = "year.month"
+
+	releaseRolling = "latest"
+)
+
+var (
+	distrosSystemDirectory = "/usr/share/toolbox/distros.d"
+
+	releaseCodenameRegexp = regexp.MustCompile("^[a-z]+$")
+
+	releaseFormats = map[string]func(string) (string, error){
+		releaseFormatCodename:   parseReleaseCodename,
+		releaseFormatInteger:    parseReleaseInteger,
+		releaseFormatMajorMinor: parseReleaseMajorMinor,
+		releaseFormatRolling:    parseReleaseRolling,
+		releaseFormatYearMonth:  parseReleaseYearMonth,
+	}
+
+	releaseYearMonthRegexp = regexp.MustCompile(`^[0-9]{2}\.(0[1-9]|1[0-2])$`)
+)
+
+// GetDistroForImage returns the distribution whose images have the same
+// basename as image, or an empty string if there is none. If several do, the
+// first one in alphabetical order wins.
+func GetDistroForImage(image string) string {
+	basename := ImageReferenceGetBasename(image)
+	if basename == "" {
+		return ""
+	}
+
+	names := make([]string, 0, len(supportedDistros))
+	for name := range supportedDistros {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if supportedDistros[name].ImageBasename == basename {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func getDistroFiles(directory string) ([]string, error) {
+	fileInfos, err := ioutil.ReadDir(directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		logrus.Debugf("Reading directory %s failed: %s", directory, err)
+		return nil, fmt.Errorf("failed to read directory %s", directory)
+	}
+
+	var files []string
+
+	for _, fileInfo := range fileInfos {
+		name := fileInfo.Name()
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".conf") {
+			continue
+		}
+
+		if !fileInfo.Mode().IsRegular() {
+			continue
+		}
+
+		files = append(files, filepath.Join(directory, name))
+	}
+
+	return files, nil
+}
+
+// parseDistro updates distroObj with the options in a [NAME] section of a
+// distribution file. A distribution that wasn't defined before must at least
+// have an image basename, a registry and a release format.
+func parseDistro(name string, distroRaw interface{}, distroObj Distro, known bool) (Distro, error) {
+	if matched, _ := regexp.MatchString(distroNameRegexp, name); !matched {
+		return distroObj, fmt.Errorf("invalid distribution name %s: must match '%s'", name, distroNameRegexp)
+	}
+
+	distro, ok := distroRaw.(map[string]interface{})
+	if !ok {
+		return distroObj, fmt.Errorf("invalid option %s: must be a table", name)
+	}
+
+	keys := make([]string, 0, len(distro))
+	for key := range distro {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var err error
+
+		switch key {
+		case "container-name-prefix", "image-basename", "registry", "release-format", "release-prefix",
+			"repository":
+			value, ok := distro[key].(string)
+			if !ok {
+				err = errors.New("must be a string")
+				break
+			}
+
+			switch key {
+			case "container-name-prefix":
+				distroObj.ContainerNamePrefix = value
+			case "image-basename":
+				distroObj.ImageBasename = value
+			case "registry":
+				distroObj.Registry = value
+			case "release-format":
+				if _, ok := releaseFormats[value]; !ok {
+					err = fmt.Errorf("unknown release format '%s'", value)
+				}
+
+				distroObj.ReleaseFormat = value
+			case "release-prefix":
+				distroObj.ReleasePrefix = value
+			case "repository":
+				distroObj.Repository = value
+			}
+		case "repository-needs-release":
+			value, ok := distro[key].(bool)
+			if !ok {
+				err = errors.New("must be a boolean")
+				break
+			}
+
+			distroObj.RepositoryNeedsRelease = value
+		default:
+			err = errors.New("unknown option")
+		}
+
+		if err != nil {
+			return distroObj, fmt.Errorf("invalid option %s.%s: %w", name, key, err)
+		}
+	}
+
+	for _, option := range []struct {
+		key   string
+		value string
+	}{
+		{"image-basename", distroObj.ImageBasename},
+		{"registry", distroObj.Registry},
+		{"release-format", distroObj.ReleaseFormat},
+	} {
+		if option.value == "" {
+			if known {
+				return distroObj, fmt.Errorf("invalid option %s.%s: must be a non-empty string",
+					name,
+					option.key)
+			}
+
+			return distroObj, fmt.Errorf("missing option %s.%s", name, option.key)
+		}
+	}
+
+	if distroObj.ContainerNamePrefix == "" {
+		distroObj.ContainerNamePrefix = distroObj.ImageBasename
+	}
+
+	if distroObj.RepositoryNeedsRelease && strings.Count(distroObj.Repository, "%s") != 1 {
+		return distroObj, fmt.Errorf("invalid option %s.repository: must contain one '%%s'", name)
+	}
+
+	return distroObj, nil
+}
+
+func (distroObj Distro) isRolling() bool {
+	return distroObj.ReleaseFormat == releaseFormatRolling
+}
+
+func (distroObj Distro) parseRelease(release string) (string, error) {
+	if prefixLength := len(distroObj.ReleasePrefix); prefixLength != 0 && len(release) > prefixLength {
+		if strings.EqualFold(release[:prefixLength], distroObj.ReleasePrefix) {
+			release = release[prefixLength:]
+		}
+	}
+
+	parseRelease, ok := releaseFormats[distroObj.ReleaseFormat]
+	if !ok {
+		panicMsg := fmt.Sprintf("failed to find release format %s", distroObj.ReleaseFormat)
+		panic(panicMsg)
+	}
+
+	release, err := parseRelease(release)
+	return release, err
+}
+
+func parseReleaseCodename(release string) (string, error) {
+	if releaseCodenameRegexp.MatchString(release) {
+		return release, nil
+	}
+
+	if _, err := parseReleaseInteger(release); err != nil {
+		return "", errors.New("release must be a positive integer or a code name")
+	}
+
+	return release, nil
+}
+
+func parseReleaseInteger(release string) (string, error) {
+	releaseN, err := strconv.Atoi(release)
+	if err != nil {
+		return "", err
+	}
+
+	if releaseN <= 0 {
+		return "", errors.New("release must be a positive integer")
+	}
+
+	return release, nil
+}
+
+func parseReleaseMajorMinor(release string) (string, error) {
+	if i := strings.IndexRune(release, '.'); i == -1 {
+		return "", errors.New("release must have a '.'")
+	}
+
+	releaseN, err := strconv.ParseFloat(release, 32)
+	if err != nil {
+		return "", err
+	}
+
+	if releaseN <= 0 {
+		return "", errors.New("release must be a positive number")
+	}
+
+	return release, nil
+}
+
+func parseReleaseRolling(release string) (string, error) {
+	if release != releaseRolling {
+		return "", fmt.Errorf("release must be '%s' for rolling distributions", releaseRolling)
+	}
+
+	return release, nil
+}
+
+func parseReleaseYearMonth(release string) (string, error) {
+	if !releaseYearMonthRegexp.MatchString(release) {
+		return "", errors.New("release must be in the YY.MM format")
+	}
+
+	return release, nil
+}
+
+// setUpDefaults picks the distribution, release and container name that are
+// used when none are specified, based on the host operating system.
+func setUpDefaults() {
+	containerNamePrefixDefault = builtinDistros["fedora"].ContainerNamePrefix
+	distroDefault = "fedora"
+	releaseDefault = releaseDefaultFallback
+
+	hostID, err := GetHostID()
+	if err == nil {
+		if distroObj, supportedDistro := supportedDistros[hostID]; supportedDistro {
+			release, err := GetHostVersionID()
+			if distroObj.isRolling() {
+				release, err = releaseRolling, nil
+			}
+
+			if err == nil {
+				containerNamePrefixDefault = distroObj.ContainerNamePrefix
+				distroDefault = hostID
+				releaseDefault = release
+			}
+		}
+	}
+
+	ContainerNameDefault = containerNamePrefixDefault + "-" + releaseDefault
+}
+
+// setUpDistros adds the distributions defined in drop-in files to the
+// built-in ones. Each *.conf file in systemDirectory and userDirectory has
+// one [NAME] section per distribution. The files are read in alphabetical
+// order, first from systemDirectory and then from userDirectory, and the
+// options in a section override those of the same distribution from the
+// built-in definitions or earlier files. Invalid files are skipped as a
+// whole, so that one of them doesn't break every command.
+func setUpDistros(systemDirectory, userDirectory string) error {
+	distros := make(map[string]Distro)
+	for name, distroObj := range builtinDistros {
+		distros[name] = distroObj
+	}
+
+	for _, directory := range []string{systemDirectory, userDirectory} {
+		files, err := getDistroFiles(directory)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			fileDistros, err := readDistroFile(file, distros)
+			if err != nil {
+				logrus.Warnf("Ignoring the distributions in %s: %s", file, err)
+				continue
+			}
+
+			for name, distroObj := range fileDistros {
+				distros[name] = distroObj
+			}
+		}
+	}
+
+	supportedDistros = distros
+	setUpDefaults()
+	return nil
+}
+
+// readDistroFile returns the distributions defined in a drop-in file, with
+// their options applied on top of those in distros.
+func readDistroFile(file string, distros map[string]Distro) (map[string]Distro, error) {
+	logrus.Debugf("Reading distributions from %s", file)
+
+	config := viper.New()
+	config.SetConfigFile(file)
+	config.SetConfigType("toml")
+
+	if err := config.ReadInConfig(); err != nil {
+		logrus.Debugf("Reading distributions from %s failed: %s", file, err)
+		return nil, errors.New("failed to parse TOML")
+	}
+
+	settings := config.AllSettings()
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fileDistros := make(map[string]Distro)
+
+	for _, name := range names {
+		distroObj, known := distros[name]
+
+		distroObj, err := parseDistro(name, settings[name], distroObj, known)
+		if err != nil {
+			return nil, err
+		}
+
+		fileDistros[name] = distroObj
+	}
+
+	return fileDistros, nil
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetUpDistros(t *testing.T) {
+	testCases := []struct {
+		name        string
+		systemFiles map[string]string
+		userFiles   map[string]string
+		distros     map[string]Distro // in addition to, or instead of, the built-in ones
+		errMsg      string            // of the user file, which is skipped
+	}{
+		{
+			name: "No files",
+		},
+		{
+			name: "New distribution",
+			systemFiles: map[string]string{
+				"centos.conf": "[centos]\n" +
+					"image-basename = \"centos-toolbox\"\n" +
+					"registry = \"quay.io\"\n" +
+					"repository = \"centos\"\n" +
+					"release-format = \"integer\"\n",
+			},
+			distros: map[string]Distro{
+				"centos": {
+					ContainerNamePrefix: "centos-toolbox",
+					ImageBasename:       "centos-toolbox",
+					Registry:            "quay.io",
+					ReleaseFormat:       releaseFormatInteger,
+					Repository:          "centos",
+				},
+			},
+		},
+		{
+			name: "User file overrides system file and built-in",
+			systemFiles: map[string]string{
+				"fedora.conf": "[fedora]\nregistry = \"registry.example.com\"\n",
+			},
+			userFiles: map[string]string{
+				"fedora.conf": "[fedora]\nrepository = \"fedora/%s\"\nrepository-needs-release = true\n",
+				"README":      "Not a distribution file",
+			},
+			distros: map[string]Distro{
+				"fedora": {
+					ContainerNamePrefix:    "fedora-toolbox",
+					ImageBasename:          "fedora-toolbox",
+					Registry:               "registry.example.com",
+					ReleaseFormat:          releaseFormatInteger,
+					ReleasePrefix:          "f",
+					Repository:             "fedora/%s",
+					RepositoryNeedsRelease: true,
+				},
+			},
+		},
+		{
+			name: "Missing option",
+			userFiles: map[string]string{
+				"centos.conf": "[centos]\nimage-basename = \"centos-toolbox\"\nrelease-format = \"integer\"\n",
+			},
+			errMsg: "missing option centos.registry",
+		},
+		{
+			name: "Unknown release format",
+			userFiles: map[string]string{
+				"fedora.conf": "[fedora]\nrelease-format = \"date\"\n",
+			},
+			errMsg: "invalid option fedora.release-format: unknown release format 'date'",
+		},
+		{
+			name: "Unknown option",
+			userFiles: map[string]string{
+				"fedora.conf": "[fedora]\nmirror = \"example.com\"\n",
+			},
+			errMsg: "invalid option fedora.mirror: unknown option",
+		},
+		{
+			name: "Repository without release",
+			userFiles: map[string]string{
+				"fedora.conf": "[fedora]\nrepository-needs-release = true\n",
+			},
+			errMsg: "invalid option fedora.repository: must contain one '%s'",
+		},
+		{
+			name: "Not a table",
+			userFiles: map[string]string{
+				"fedora.conf": "fedora = \"registry.example.com\"\n",
+			},
+			errMsg: "invalid option fedora: must be a table",
+		},
+		{
+			name: "Invalid TOML",
+			userFiles: map[string]string{
+				"fedora.conf": "[fedora\n",
+			},
+			errMsg: "failed to parse TOML",
+		},
+		{
+			name: "Invalid file is skipped",
+			systemFiles: map[string]string{
+				"centos.conf": "[centos]\n" +
+					"image-basename = \"centos-toolbox\"\n" +
+					"registry = \"quay.io\"\n" +
+					"release-format = \"integer\"\n",
+			},
+			userFiles: map[string]string{
+				"centos.conf": "[centos]\nregistry = \"registry.example.com\"\n\n[fedora\n",
+			},
+			distros: map[string]Distro{
+				"centos": {
+					ContainerNamePrefix: "centos-toolbox",
+					ImageBasename:       "centos-toolbox",
+					Registry:            "quay.io",
+					ReleaseFormat:       releaseFormatInteger,
+				},
+			},
+			errMsg: "failed to parse TOML",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "toolbox-distros")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			defer func() {
+				supportedDistros = builtinDistros
+				setUpDefaults()
+			}()
+
+			systemDirectory := filepath.Join(dir, "system")
+			userDirectory := filepath.Join(dir, "user")
+
+			for directory, files := range map[string]map[string]string{
+				systemDirectory: tc.systemFiles,
+				userDirectory:   tc.userFiles,
+			} {
+				err := os.Mkdir(directory, 0755)
+				require.NoError(t, err)
+
+				for name, content := range files {
+					err := ioutil.WriteFile(filepath.Join(directory, name), []byte(content), 0644)
+					require.NoError(t, err)
+				}
+			}
+
+			if tc.errMsg != "" {
+				for name := range tc.userFiles {
+					_, err := readDistroFile(filepath.Join(userDirectory, name), builtinDistros)
+					assert.EqualError(t, err, tc.errMsg)
+				}
+			}
+
+			err = setUpDistros(systemDirectory, userDirectory)
+
+			distros := make(map[string]Distro)
+			for name, distroObj := range builtinDistros {
+				distros[name] = distroObj
+			}
+
+			for name, distroObj := range tc.distros {
+				distros[name] = distroObj
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, distros, supportedDistros)
+		})
+	}
+}
+
+func TestSetUpDistrosMissingDirectories(t *testing.T) {
+	err := setUpDistros("/does/not/exist", "/does/not/exist/either")
+	assert.NoError(t, err)
+	assert.Equal(t, builtinDistros, supportedDistros)
+}
+
+func TestParseReleaseDropIn(t *testing.T) {
+	defer func() {
+		supportedDistros = builtinDistros
+		setUpDefaults()
+	}()
+
+	supportedDistros = map[string]Distro{
+		"centos": {
+			ContainerNamePrefix: "centos-toolbox",
+			ImageBasename:       "centos-toolbox",
+			Registry:            "quay.io",
+			ReleaseFormat:       releaseFormatInteger,
+			ReleasePrefix:       "stream",
+		},
+	}
+
+	release, err := ParseRelease("centos", "Stream9")
+	assert.NoError(t, err)
+	assert.Equal(t, "9", release)
+
+	_, err = ParseRelease("centos", "0")
+	assert.EqualError(t, err, "release must be a positive integer")
+}
+
+func TestGetDistroForImageSameBasename(t *testing.T) {
+	defer func() {
+		supportedDistros = builtinDistros
+		setUpDefaults()
+	}()
+
+	supportedDistros = map[string]Distro{
+		"rhel":       {ImageBasename: "toolbox"},
+		"almalinux":  {ImageBasename: "toolbox"},
+		"centos":     {ImageBasename: "centos-toolbox"},
+		"rockylinux": {ImageBasename: "toolbox"},
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "almalinux", GetDistroForImage("quay.io/example/toolbox:9"))
+	}
+}
@@ -0,0 +1,273 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	envPatternRegexp = "^[a-zA-Z0-9_*?]+$"
+)
+
+var (
+	// environmentDenyConfig and environmentPreserveConfig are the
+	// deny-environment and preserve-environment options in the [general]
+	// section of the configuration files.
+	environmentDenyConfig     []string
+	environmentPreserveConfig []string
+)
+
+// GetEnvOptionsForPreservedVariables returns the --env options that forward
+// environment variables into a container, or to the host from inside one.
+//
+// A variable is forwarded according to the first of these that applies:
+//   - deny-environment of the container's [containers.NAME] section
+//   - preserve-environment of the container's [containers.NAME] section
+//   - deny-environment of the [general] section
+//   - preserve-environment of the [general] section
+//   - the built-in list of variables that are always forwarded
+//
+// The built-in variables come first, and the others are sorted by name.
+func GetEnvOptionsForPreservedVariables(container string) []string {
+	logrus.Debug("Creating list of environment variables to forward")
+
+	var envOptions []string
+
+	for _, variable := range getPreservedEnvironmentVariables(container) {
+		value := os.Getenv(variable)
+		logrus.Debugf("%s=%s", variable, value)
+		envOptions = append(envOptions, fmt.Sprintf("--env=%s=%s", variable, value))
+	}
+
+	return envOptions
+}
+
+// ReadEnvironmentFile reads environment variables from a file with one
+// NAME[=VALUE] per line, like the --env-file option of 'podman run'. Empty
+// lines and lines starting with '#' are ignored.
+func ReadEnvironmentFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logrus.Debugf("Reading environment file %s failed: %s", path, err)
+		return nil, fmt.Errorf("failed to read environment file %s", path)
+	}
+
+	var envs []string
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " \t")
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := ValidateEnvironmentVariable(line); err != nil {
+			return nil, fmt.Errorf("%s in environment file %s, line %d", err, path, i+1)
+		}
+
+		envs = append(envs, line)
+	}
+
+	return envs, nil
+}
+
+// getCurrentContainerName returns the name of the container that toolbox is
+// running in from its /run/.containerenv file, or an empty string if it's
+// unknown, like with old versions of Podman.
+func getCurrentContainerName(containerEnvFile string) string {
+	file, err := os.Open(containerEnvFile)
+	if err != nil {
+		logrus.Debugf("Reading %s failed: %s", containerEnvFile, err)
+		return ""
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "name=") {
+			continue
+		}
+
+		name := strings.TrimPrefix(line, "name=")
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+
+		return name
+	}
+
+	return ""
+}
+
+// getPreservedEnvironmentVariables returns the names of the environment
+// variables that are set and forwarded for a container.
+func getPreservedEnvironmentVariables(container string) []string {
+	var profile *ContainerProfile
+	if container != "" {
+		var err error
+		profile, err = GetContainerProfile(container)
+		if err != nil {
+			logrus.Warnf("Ignoring the configuration of container %s: %s", container, err)
+		}
+	}
+
+	var variables []string
+	seen := make(map[string]bool)
+
+	for _, variable := range preservedEnvironmentVariables {
+		seen[variable] = true
+
+		if _, found := os.LookupEnv(variable); !found {
+			logrus.Debugf("%s is unset", variable)
+			continue
+		}
+
+		if !isEnvironmentVariablePreserved(variable, profile) {
+			logrus.Debugf("%s is denied", variable)
+			continue
+		}
+
+		variables = append(variables, variable)
+	}
+
+	var others []string
+
+	for _, env := range os.Environ() {
+		variable := env
+		if i := strings.IndexRune(env, '='); i != -1 {
+			variable = env[:i]
+		}
+
+		if variable == "" || seen[variable] {
+			continue
+		}
+
+		seen[variable] = true
+
+		if isEnvironmentVariablePreserved(variable, profile) {
+			others = append(others, variable)
+		}
+	}
+
+	sort.Strings(others)
+	variables = append(variables, others...)
+
+	return variables
+}
+
+func isEnvironmentVariablePreserved(variable string, profile *ContainerProfile) bool {
+	if profile != nil {
+		if matchEnvironmentPatterns(profile.DenyEnvironment, variable) {
+			return false
+		}
+
+		if matchEnvironmentPatterns(profile.PreserveEnvironment, variable) {
+			return true
+		}
+	}
+
+	if matchEnvironmentPatterns(environmentDenyConfig, variable) {
+		return false
+	}
+
+	if matchEnvironmentPatterns(environmentPreserveConfig, variable) {
+		return true
+	}
+
+	for _, preservedVariable := range preservedEnvironmentVariables {
+		if variable == preservedVariable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchEnvironmentPatterns checks if the name of an environment variable
+// matches any of the patterns, where '*' matches any number of characters and
+// '?' matches a single one. Matching is case sensitive.
+func matchEnvironmentPatterns(patterns []string, variable string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, variable); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setUpEnvironmentPolicy() error {
+	environmentDenyConfig = nil
+	environmentPreserveConfig = nil
+
+	options := []struct {
+		key      string
+		patterns *[]string
+	}{
+		{"deny-environment", &environmentDenyConfig},
+		{"preserve-environment", &environmentPreserveConfig},
+	}
+
+	for _, option := range options {
+		if !viper.IsSet("general." + option.key) {
+			continue
+		}
+
+		general := map[string]interface{}{
+			option.key: viper.Get("general." + option.key),
+		}
+
+		patterns, err := getStringSliceOption(general, option.key, validateEnvironmentPattern)
+		if err != nil {
+			return fmt.Errorf("invalid option general.%s: %w", option.key, err)
+		}
+
+		*option.patterns = patterns
+	}
+
+	return nil
+}
+
+func validateEnvironmentPattern(pattern string) error {
+	matched, err := regexp.MatchString(envPatternRegexp, pattern)
+	if err != nil {
+		panicMsg := fmt.Sprintf("failed to parse regular expression for environment variable pattern: %v",
+			err)
+		panic(panicMsg)
+	}
+
+	if !matched {
+		return fmt.Errorf("environment variable pattern %s must match '%s'", pattern, envPatternRegexp)
+	}
+
+	return nil
+}
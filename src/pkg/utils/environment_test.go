@@ -0,0 +1,294 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEnvOptionsForPreservedVariables(t *testing.T) {
+	environment := map[string]string{
+		"DISPLAY":     ":0",
+		"EDITOR":      "vim",
+		"HTTPS_PROXY": "http://proxy:3128",
+		"HTTP_PROXY":  "http://proxy:3128",
+		"LC_TIME":     "en_GB.UTF-8",
+		"PAGER":       "less",
+		"TERM":        "xterm-256color",
+	}
+
+	testCases := []struct {
+		name       string
+		config     string
+		container  string
+		envOptions []string
+	}{
+		{
+			name:   "Built-in variables only",
+			config: "",
+			envOptions: []string{
+				"--env=DISPLAY=:0",
+				"--env=TERM=xterm-256color",
+			},
+		},
+		{
+			name:   "Preserved patterns",
+			config: "[general]\npreserve-environment = [\"LC_*\", \"*_PROXY\", \"EDITOR\"]\n",
+			envOptions: []string{
+				"--env=DISPLAY=:0",
+				"--env=TERM=xterm-256color",
+				"--env=EDITOR=vim",
+				"--env=HTTPS_PROXY=http://proxy:3128",
+				"--env=HTTP_PROXY=http://proxy:3128",
+				"--env=LC_TIME=en_GB.UTF-8",
+			},
+		},
+		{
+			name: "Denied patterns",
+			config: "[general]\n" +
+				"preserve-environment = [\"*_PROXY\"]\n" +
+				"deny-environment = [\"DISPLAY\", \"HTTPS_*\"]\n",
+			envOptions: []string{
+				"--env=TERM=xterm-256color",
+				"--env=HTTP_PROXY=http://proxy:3128",
+			},
+		},
+		{
+			name: "Container overrides",
+			config: "[general]\n" +
+				"preserve-environment = [\"*_PROXY\"]\n" +
+				"deny-environment = [\"DISPLAY\"]\n" +
+				"[containers.devel]\n" +
+				"preserve-environment = [\"DISPLAY\", \"PAGER\"]\n" +
+				"deny-environment = [\"*_PROXY\"]\n",
+			container: "devel",
+			envOptions: []string{
+				"--env=DISPLAY=:0",
+				"--env=TERM=xterm-256color",
+				"--env=PAGER=less",
+			},
+		},
+		{
+			name: "Other container",
+			config: "[general]\n" +
+				"deny-environment = [\"DISPLAY\"]\n" +
+				"[containers.devel]\n" +
+				"preserve-environment = [\"DISPLAY\", \"PAGER\"]\n",
+			container: "fedora-toolbox-35",
+			envOptions: []string{
+				"--env=TERM=xterm-256color",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			environOld := os.Environ()
+			os.Clearenv()
+
+			for key, value := range environment {
+				os.Setenv(key, value)
+			}
+
+			viper.Reset()
+
+			defer func() {
+				viper.Reset()
+				setUpContainerProfiles()
+				setUpEnvironmentPolicy()
+
+				os.Clearenv()
+				for _, env := range environOld {
+					i := strings.IndexRune(env, '=')
+					os.Setenv(env[:i], env[i+1:])
+				}
+			}()
+
+			viper.SetConfigType("toml")
+			err := viper.ReadConfig(strings.NewReader(tc.config))
+			require.NoError(t, err)
+
+			err = setUpContainerProfiles()
+			require.NoError(t, err)
+
+			err = setUpEnvironmentPolicy()
+			require.NoError(t, err)
+
+			envOptions := GetEnvOptionsForPreservedVariables(tc.container)
+			assert.Equal(t, tc.envOptions, envOptions)
+		})
+	}
+}
+
+func TestReadEnvironmentFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		envs    []string
+		errMsg  string
+	}{
+		{
+			name:    "Variables, comments and empty lines",
+			content: "# Build settings\nCFLAGS=-O2 -g\n\n  MAKEFLAGS=-j4\r\n#CC=clang\nSSH_AUTH_SOCK\nEMPTY=\n",
+			envs:    []string{"CFLAGS=-O2 -g", "MAKEFLAGS=-j4", "SSH_AUTH_SOCK", "EMPTY="},
+		},
+		{
+			name:    "Empty",
+			content: "# Nothing\n\n",
+		},
+		{
+			name:    "Invalid name",
+			content: "CC=gcc\nexport CXX=g++\n",
+			errMsg: "environment variable name export CXX must match '^[a-zA-Z_][a-zA-Z0-9_]*$' " +
+				"in environment file %s, line 2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "toolbox-environment")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "environment")
+			err = ioutil.WriteFile(path, []byte(tc.content), 0644)
+			require.NoError(t, err)
+
+			envs, err := ReadEnvironmentFile(path)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, fmt.Sprintf(tc.errMsg, path))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.envs, envs)
+		})
+	}
+
+	_, err := ReadEnvironmentFile("/does/not/exist")
+	assert.EqualError(t, err, "failed to read environment file /does/not/exist")
+}
+
+func TestGetCurrentContainerName(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		container string
+	}{
+		{
+			name:      "Podman",
+			content:   "engine=\"podman-3.4.4\"\nname=\"fedora-toolbox-35\"\nid=\"abc\"\nrootless=1\n",
+			container: "fedora-toolbox-35",
+		},
+		{
+			name:    "Old Podman",
+			content: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "toolbox-containerenv")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, ".containerenv")
+			err = ioutil.WriteFile(path, []byte(tc.content), 0644)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.container, getCurrentContainerName(path))
+		})
+	}
+
+	assert.Equal(t, "", getCurrentContainerName("/does/not/exist"))
+}
+
+func TestSetUpEnvironmentPolicy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   string
+		deny     []string
+		preserve []string
+		errMsg   string
+	}{
+		{
+			name:   "Not set",
+			config: "[general]\ndistro = \"fedora\"\n",
+		},
+		{
+			name: "Patterns",
+			config: "[general]\n" +
+				"preserve-environment = [\"LC_*\", \"*_PROXY\", \"EDITOR\"]\n" +
+				"deny-environment = [\"XDG_SESSION_?D\"]\n",
+			deny:     []string{"XDG_SESSION_?D"},
+			preserve: []string{"LC_*", "*_PROXY", "EDITOR"},
+		},
+		{
+			name:   "Value",
+			config: "[general]\npreserve-environment = [\"EDITOR=vim\"]\n",
+			errMsg: "invalid option general.preserve-environment: " +
+				"environment variable pattern EDITOR=vim must match '^[a-zA-Z0-9_*?]+$'",
+		},
+		{
+			name:   "Character class",
+			config: "[general]\npreserve-environment = [\"[A-Z]*\"]\n",
+			errMsg: "invalid option general.preserve-environment: " +
+				"environment variable pattern [A-Z]* must match '^[a-zA-Z0-9_*?]+$'",
+		},
+		{
+			name:   "Not an array",
+			config: "[general]\npreserve-environment = \"EDITOR\"\n",
+			errMsg: "invalid option general.preserve-environment: must be an array of strings",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Reset()
+
+			defer func() {
+				viper.Reset()
+				setUpEnvironmentPolicy()
+			}()
+
+			viper.SetConfigType("toml")
+			err := viper.ReadConfig(strings.NewReader(tc.config))
+			require.NoError(t, err)
+
+			err = setUpEnvironmentPolicy()
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.deny, environmentDenyConfig)
+			assert.Equal(t, tc.preserve, environmentPreserveConfig)
+		})
+	}
+}
@@ -0,0 +1,344 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ContainerProfile describes a toolbox container declared in a
+// [containers.NAME] section of toolbox.conf, so that it can be reproduced on
+// every host with the same configuration.
+type ContainerProfile struct {
+	Name        string
+	Distro      string
+	Image       string
+	Release     string
+	Devices     []string
+	Environment []string
+	Packages    []string
+	Volumes     []string
+
+	// DenyEnvironment and PreserveEnvironment override the environment
+	// policy of the [general] section for this container.
+	DenyEnvironment     []string
+	PreserveEnvironment []string
+}
+
+const (
+	envNameRegexp = "^[a-zA-Z_][a-zA-Z0-9_]*$"
+)
+
+var (
+	// containerProfiles holds the unparsed [containers.NAME] sections of
+	// the configuration files. A section is only validated when its
+	// container is used, so that a mistake in one of them doesn't break
+	// every command.
+	containerProfiles map[string]interface{}
+)
+
+// GetContainerProfile returns the profile declared for a container in the
+// configuration files, or nil if there is none. An error is returned if the
+// profile is invalid.
+//
+// Viper doesn't preserve the case of keys, so the names of profiles are case
+// insensitive.
+func GetContainerProfile(container string) (*ContainerProfile, error) {
+	containerLower := strings.ToLower(container)
+	profileRaw, found := containerProfiles[containerLower]
+	if !found {
+		return nil, nil
+	}
+
+	profile, err := parseContainerProfile(containerLower, profileRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.Name = container
+	return &profile, nil
+}
+
+// ValidateDevice checks if device has the format HOST-DEVICE[:CONTAINER-DEVICE][:PERMISSIONS]
+// that is used by the --device option of 'podman create'.
+func ValidateDevice(device string) error {
+	parts := strings.Split(device, ":")
+	if len(parts) > 3 {
+		return errors.New("device must be HOST-DEVICE[:CONTAINER-DEVICE][:PERMISSIONS]")
+	}
+
+	if !filepath.IsAbs(parts[0]) {
+		return fmt.Errorf("host device %s must be an absolute path", parts[0])
+	}
+
+	if len(parts) == 1 {
+		return nil
+	}
+
+	permissions := parts[len(parts)-1]
+	if len(parts) == 2 && filepath.IsAbs(permissions) {
+		return nil
+	}
+
+	if len(parts) == 3 && !filepath.IsAbs(parts[1]) {
+		return fmt.Errorf("container device %s must be an absolute path", parts[1])
+	}
+
+	if permissions == "" || strings.Trim(permissions, "rwm") != "" {
+		return fmt.Errorf("device permissions %s must be a combination of r, w and m", permissions)
+	}
+
+	return nil
+}
+
+// ValidateEnvironmentVariable checks if env has the format NAME[=VALUE] that is
+// used by the --env option of 'podman create'.
+func ValidateEnvironmentVariable(env string) error {
+	name := env
+	if i := strings.IndexRune(env, '='); i != -1 {
+		name = env[:i]
+	}
+
+	matched, err := regexp.MatchString(envNameRegexp, name)
+	if err != nil {
+		panicMsg := fmt.Sprintf("failed to parse regular expression for environment variable: %v", err)
+		panic(panicMsg)
+	}
+
+	if !matched {
+		return fmt.Errorf("environment variable name %s must match '%s'", name, envNameRegexp)
+	}
+
+	return nil
+}
+
+// ValidateVolume checks if volume has the format SOURCE:DESTINATION[:OPTIONS]
+// that is used by the --volume option of 'podman create'.
+func ValidateVolume(volume string) error {
+	parts := strings.Split(volume, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return errors.New("volume must be SOURCE:DESTINATION[:OPTIONS]")
+	}
+
+	source := parts[0]
+	if source == "" {
+		return errors.New("volume source must not be empty")
+	}
+
+	if !filepath.IsAbs(source) && !IsContainerNameValid(source) {
+		return fmt.Errorf("volume source %s must be an absolute path or a volume name", source)
+	}
+
+	destination := parts[1]
+	if !filepath.IsAbs(destination) {
+		return fmt.Errorf("volume destination %s must be an absolute path", destination)
+	}
+
+	if len(parts) == 3 && parts[2] == "" {
+		return errors.New("volume options must not be empty")
+	}
+
+	return nil
+}
+
+// ReadPackagesFile returns the packages listed in a file, one per line. Empty
+// lines and lines starting with '#' are ignored.
+func ReadPackagesFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logrus.Debugf("Reading packages file %s failed: %s", path, err)
+		return nil, fmt.Errorf("failed to read packages file %s", path)
+	}
+
+	var packages []string
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := validatePackage(line); err != nil {
+			return nil, fmt.Errorf("%s in packages file %s, line %d", err, path, i+1)
+		}
+
+		packages = append(packages, line)
+	}
+
+	return packages, nil
+}
+
+// getStringSliceOption returns the array of strings held by key, after
+// checking each string with validate.
+func getStringSliceOption(profile map[string]interface{},
+	key string,
+	validate func(string) error) ([]string, error) {
+	values, ok := profile[key].([]interface{})
+	if !ok {
+		return nil, errors.New("must be an array of strings")
+	}
+
+	var strs []string
+
+	for _, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			return nil, errors.New("must be an array of strings")
+		}
+
+		if err := validate(str); err != nil {
+			return nil, err
+		}
+
+		strs = append(strs, str)
+	}
+
+	return strs, nil
+}
+
+func validatePackage(pkg string) error {
+	if pkg == "" || strings.HasPrefix(pkg, "-") || strings.ContainsAny(pkg, " \t\n") {
+		return fmt.Errorf("invalid package name '%s'", pkg)
+	}
+
+	return nil
+}
+
+func parseContainerProfile(name string, profileRaw interface{}) (ContainerProfile, error) {
+	var containerProfile ContainerProfile
+
+	if !IsContainerNameValid(name) {
+		return containerProfile, fmt.Errorf("invalid container name containers.%s: must match '%s'",
+			name,
+			ContainerNameRegexp)
+	}
+
+	profile, ok := profileRaw.(map[string]interface{})
+	if !ok {
+		return containerProfile, fmt.Errorf("invalid option containers.%s: must be a table", name)
+	}
+
+	keys := make([]string, 0, len(profile))
+	for key := range profile {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var err error
+
+		switch key {
+		case "deny-environment":
+			containerProfile.DenyEnvironment, err = getStringSliceOption(profile,
+				key,
+				validateEnvironmentPattern)
+		case "devices":
+			containerProfile.Devices, err = getStringSliceOption(profile, key, ValidateDevice)
+		case "distro", "image", "release":
+			value, ok := profile[key].(string)
+			if !ok || value == "" {
+				err = errors.New("must be a non-empty string")
+				break
+			}
+
+			switch key {
+			case "distro":
+				containerProfile.Distro = value
+			case "image":
+				containerProfile.Image = value
+			case "release":
+				containerProfile.Release = value
+			}
+		case "environment":
+			containerProfile.Environment, err = getStringSliceOption(profile,
+				key,
+				ValidateEnvironmentVariable)
+		case "packages":
+			containerProfile.Packages, err = getStringSliceOption(profile, key, validatePackage)
+		case "preserve-environment":
+			containerProfile.PreserveEnvironment, err = getStringSliceOption(profile,
+				key,
+				validateEnvironmentPattern)
+		case "volumes":
+			containerProfile.Volumes, err = getStringSliceOption(profile, key, ValidateVolume)
+		default:
+			err = errors.New("unknown option")
+		}
+
+		if err != nil {
+			return containerProfile, fmt.Errorf("invalid option containers.%s.%s: %w", name, key, err)
+		}
+	}
+
+	if containerProfile.Image != "" {
+		if containerProfile.Distro != "" {
+			return containerProfile, fmt.Errorf("invalid option containers.%s.image: %s",
+				name,
+				"cannot be used together with distro")
+		}
+
+		if containerProfile.Release != "" {
+			return containerProfile, fmt.Errorf("invalid option containers.%s.image: %s",
+				name,
+				"cannot be used together with release")
+		}
+	}
+
+	if containerProfile.Release != "" {
+		release, err := ParseRelease(containerProfile.Distro, containerProfile.Release)
+		if err != nil {
+			var numErr *strconv.NumError
+			if errors.As(err, &numErr) {
+				err = fmt.Errorf("release %s must be a number", containerProfile.Release)
+			}
+
+			return containerProfile, fmt.Errorf("invalid option containers.%s.release: %w", name, err)
+		}
+
+		containerProfile.Release = release
+	}
+
+	return containerProfile, nil
+}
+
+func setUpContainerProfiles() error {
+	containerProfiles = nil
+
+	if !viper.IsSet("containers") {
+		return nil
+	}
+
+	profiles, ok := viper.Get("containers").(map[string]interface{})
+	if !ok {
+		return errors.New("invalid option containers: must be a table")
+	}
+
+	containerProfiles = profiles
+	return nil
+}
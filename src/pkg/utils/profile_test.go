@@ -0,0 +1,200 @@
+/*
+ * Copyright © 2021 Red Hat Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetContainerProfile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  string
+		profile *ContainerProfile
+		errMsg  string
+	}{
+		{
+			name:   "No profiles",
+			config: "[general]\ndistro = \"fedora\"\n",
+		},
+		{
+			name: "Valid",
+			config: `[containers.devel]
+distro = "fedora"
+release = "f35"
+volumes = ["/opt/sdk:/opt/sdk:ro", "cache:/var/cache/dnf"]
+environment = ["FOO=bar", "BAZ"]
+devices = ["/dev/kvm", "/dev/fuse:/dev/fuse:rw"]
+packages = ["gcc", "make"]
+preserve-environment = ["LC_*", "*_PROXY"]
+deny-environment = ["DISPLAY"]
+`,
+			profile: &ContainerProfile{
+				Name:                "devel",
+				Distro:              "fedora",
+				Release:             "35",
+				Devices:             []string{"/dev/kvm", "/dev/fuse:/dev/fuse:rw"},
+				Environment:         []string{"FOO=bar", "BAZ"},
+				Packages:            []string{"gcc", "make"},
+				Volumes:             []string{"/opt/sdk:/opt/sdk:ro", "cache:/var/cache/dnf"},
+				DenyEnvironment:     []string{"DISPLAY"},
+				PreserveEnvironment: []string{"LC_*", "*_PROXY"},
+			},
+		},
+		{
+			name:   "Unknown option",
+			config: "[containers.devel]\nimgae = \"foo\"\n",
+			errMsg: "invalid option containers.devel.imgae: unknown option",
+		},
+		{
+			name:   "Invalid volume",
+			config: "[containers.devel]\nvolumes = [\"/opt/sdk\"]\n",
+			errMsg: "invalid option containers.devel.volumes: volume must be SOURCE:DESTINATION[:OPTIONS]",
+		},
+		{
+			name:   "Relative volume destination",
+			config: "[containers.devel]\nvolumes = [\"/opt/sdk:opt\"]\n",
+			errMsg: "invalid option containers.devel.volumes: volume destination opt must be an absolute path",
+		},
+		{
+			name:   "Invalid environment",
+			config: "[containers.devel]\nenvironment = [\"1FOO=bar\"]\n",
+			errMsg: "invalid option containers.devel.environment: " +
+				"environment variable name 1FOO must match '^[a-zA-Z_][a-zA-Z0-9_]*$'",
+		},
+		{
+			name:   "Invalid environment pattern",
+			config: "[containers.devel]\npreserve-environment = [\"LC_*=C\"]\n",
+			errMsg: "invalid option containers.devel.preserve-environment: " +
+				"environment variable pattern LC_*=C must match '^[a-zA-Z0-9_*?]+$'",
+		},
+		{
+			name:   "Invalid device permissions",
+			config: "[containers.devel]\ndevices = [\"/dev/kvm:/dev/kvm:rx\"]\n",
+			errMsg: "invalid option containers.devel.devices: " +
+				"device permissions rx must be a combination of r, w and m",
+		},
+		{
+			name:   "Not an array",
+			config: "[containers.devel]\npackages = \"gcc\"\n",
+			errMsg: "invalid option containers.devel.packages: must be an array of strings",
+		},
+		{
+			name:   "Image and release",
+			config: "[containers.devel]\nimage = \"foo:1\"\nrelease = \"35\"\n",
+			errMsg: "invalid option containers.devel.image: cannot be used together with release",
+		},
+		{
+			name:   "Invalid release",
+			config: "[containers.devel]\ndistro = \"fedora\"\nrelease = \"foo\"\n",
+			errMsg: "invalid option containers.devel.release: release foo must be a number",
+		},
+		{
+			name: "Invalid other container",
+			config: "[containers.devel]\ndistro = \"fedora\"\n" +
+				"[containers.broken]\nvolumes = [\"/opt/sdk\"]\n",
+			profile: &ContainerProfile{Name: "devel", Distro: "fedora"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+
+			viper.SetConfigType("toml")
+			err := viper.ReadConfig(strings.NewReader(tc.config))
+			require.NoError(t, err)
+
+			err = setUpContainerProfiles()
+			require.NoError(t, err)
+
+			profile, err := GetContainerProfile("devel")
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.profile, profile)
+		})
+	}
+}
+
+func TestReadPackagesFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		packages []string
+		errMsg   string
+	}{
+		{
+			name:     "Packages, comments and empty lines",
+			content:  "# Build tools\ngcc\n\n  make  \n#meson\nninja-build\n",
+			packages: []string{"gcc", "make", "ninja-build"},
+		},
+		{
+			name:    "Empty",
+			content: "# Nothing\n\n",
+		},
+		{
+			name:    "Invalid package",
+			content: "gcc\n--nogpgcheck\n",
+			errMsg:  "invalid package name '--nogpgcheck' in packages file %s, line 2",
+		},
+		{
+			name:    "Two packages on a line",
+			content: "gcc make\n",
+			errMsg:  "invalid package name 'gcc make' in packages file %s, line 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "toolbox-packages")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "packages")
+			err = ioutil.WriteFile(path, []byte(tc.content), 0644)
+			require.NoError(t, err)
+
+			packages, err := ReadPackagesFile(path)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, fmt.Sprintf(tc.errMsg, path))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.packages, packages)
+		})
+	}
+
+	_, err := ReadPackagesFile("/does/not/exist")
+	assert.EqualError(t, err, "failed to read packages file /does/not/exist")
+}
@@ -38,13 +38,15 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-type ParseReleaseFunc func(string) (string, error)
-
+// Distro describes an operating system distribution that can be used with
+// --distro. Besides the built-in ones, distributions can be defined in
+// drop-in files. See setUpDistros.
 type Distro struct {
 	ContainerNamePrefix    string
 	ImageBasename          string
-	ParseRelease           ParseReleaseFunc
 	Registry               string
+	ReleaseFormat          string
+	ReleasePrefix          string
 	Repository             string
 	RepositoryNeedsRelease bool
 }
@@ -93,24 +95,45 @@ var (
 
 	releaseDefault string
 
-	supportedDistros = map[string]Distro{
+	builtinDistros = map[string]Distro{
+		"arch": {
+			ContainerNamePrefix: "arch-toolbox",
+			ImageBasename:       "arch-toolbox",
+			Registry:            "quay.io",
+			ReleaseFormat:       releaseFormatRolling,
+			Repository:          "toolbx",
+		},
+		"debian": {
+			ContainerNamePrefix: "debian-toolbox",
+			ImageBasename:       "debian-toolbox",
+			Registry:            "quay.io",
+			ReleaseFormat:       releaseFormatCodename,
+			Repository:          "toolbx-images",
+		},
 		"fedora": {
-			"fedora-toolbox",
-			"fedora-toolbox",
-			parseReleaseFedora,
-			"registry.fedoraproject.org",
-			"",
-			false,
+			ContainerNamePrefix: "fedora-toolbox",
+			ImageBasename:       "fedora-toolbox",
+			Registry:            "registry.fedoraproject.org",
+			ReleaseFormat:       releaseFormatInteger,
+			ReleasePrefix:       "f",
 		},
 		"rhel": {
-			"rhel-toolbox",
-			"toolbox",
-			parseReleaseRHEL,
-			"registry.access.redhat.com",
-			"ubi8",
-			false,
+			ContainerNamePrefix: "rhel-toolbox",
+			ImageBasename:       "toolbox",
+			Registry:            "registry.access.redhat.com",
+			ReleaseFormat:       releaseFormatMajorMinor,
+			Repository:          "ubi8",
+		},
+		"ubuntu": {
+			ContainerNamePrefix: "ubuntu-toolbox",
+			ImageBasename:       "ubuntu-toolbox",
+			Registry:            "quay.io",
+			ReleaseFormat:       releaseFormatYearMonth,
+			Repository:          "toolbx",
 		},
 	}
+
+	supportedDistros = builtinDistros
 )
 
 var (
@@ -118,21 +141,7 @@ var (
 )
 
 func init() {
-	releaseDefault = releaseDefaultFallback
-
-	hostID, err := GetHostID()
-	if err == nil {
-		if distroObj, supportedDistro := supportedDistros[hostID]; supportedDistro {
-			release, err := GetHostVersionID()
-			if err == nil {
-				containerNamePrefixDefault = distroObj.ContainerNamePrefix
-				distroDefault = hostID
-				releaseDefault = release
-			}
-		}
-	}
-
-	ContainerNameDefault = containerNamePrefixDefault + "-" + releaseDefault
+	setUpDefaults()
 }
 
 func CallFlatpakSessionHelper() (string, error) {
@@ -175,8 +184,19 @@ func EnsureXdgRuntimeDirIsSet(uid int) {
 	}
 }
 
+// FirstName returns the first of the names of a container or image, or an
+// empty string if there are none.
+func FirstName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	return names[0]
+}
+
 func ForwardToHost() (int, error) {
-	envOptions := GetEnvOptionsForPreservedVariables()
+	container := getCurrentContainerName("/run/.containerenv")
+	envOptions := GetEnvOptionsForPreservedVariables(container)
 	toolboxPath := os.Getenv("TOOLBOX_PATH")
 	commandLineArgs := os.Args[1:]
 
@@ -197,7 +217,7 @@ func ForwardToHost() (int, error) {
 		logrus.Debugf("%s", arg)
 	}
 
-	exitCode, err := shell.RunWithExitCode("flatpak-spawn", os.Stdin, os.Stdout, nil, flatpakSpawnArgs...)
+	exitCode, err := shell.RunWithExitCode("flatpak-spawn", os.Stdin, os.Stdout, os.Stderr, flatpakSpawnArgs...)
 	if err != nil {
 		return exitCode, err
 	}
@@ -256,25 +276,6 @@ func getDefaultImageForDistro(distro, release string) string {
 	return image
 }
 
-func GetEnvOptionsForPreservedVariables() []string {
-	logrus.Debug("Creating list of environment variables to forward")
-
-	var envOptions []string
-
-	for _, variable := range preservedEnvironmentVariables {
-		value, found := os.LookupEnv(variable)
-		if !found {
-			logrus.Debugf("%s is unset", variable)
-			continue
-		}
-
-		logrus.Debugf("%s=%s", variable, value)
-		envOptions = append(envOptions, fmt.Sprintf("--env=%s=%s", variable, value))
-	}
-
-	return envOptions
-}
-
 func GetFullyQualifiedImageFromDistros(image, release string) (string, error) {
 	logrus.Debugf("Resolving fully qualified name for image %s from known registries", image)
 
@@ -446,6 +447,24 @@ func GetRuntimeDirectory(targetUser *user.User) (string, error) {
 	return toolboxRuntimeDirectory, nil
 }
 
+// GetStateDirectory returns the directory where Toolbox keeps data that is
+// meant to survive a reboot, as described by the XDG Base Directory
+// Specification. It isn't created.
+func GetStateDirectory() (string, error) {
+	stateDirectory := os.Getenv("XDG_STATE_HOME")
+	if stateDirectory == "" {
+		homeDirectory, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get the home directory: %w", err)
+		}
+
+		stateDirectory = filepath.Join(homeDirectory, ".local", "state")
+	}
+
+	toolboxStateDirectory := filepath.Join(stateDirectory, "toolbox")
+	return toolboxStateDirectory, nil
+}
+
 // HumanDuration accepts a Unix time value and converts it into a human readable
 // string.
 //
@@ -573,6 +592,21 @@ func SetUpConfiguration() error {
 		}
 	}
 
+	if err := setUpDistros(distrosSystemDirectory, userConfigDir+"/toolbox/distros.d"); err != nil {
+		logrus.Debugf("Setting up configuration: failed to set up distributions: %s", err)
+		return err
+	}
+
+	if err := setUpContainerProfiles(); err != nil {
+		logrus.Debugf("Setting up configuration: failed to set up container profiles: %s", err)
+		return err
+	}
+
+	if err := setUpEnvironmentPolicy(); err != nil {
+		logrus.Debugf("Setting up configuration: failed to set up environment policy: %s", err)
+		return err
+	}
+
 	image, release, err := ResolveImageName("", "", "")
 	if err != nil {
 		logrus.Debugf("Setting up configuration: failed to resolve image name: %s", err)
@@ -616,45 +650,10 @@ func ParseRelease(distro, release string) (string, error) {
 		panic(panicMsg)
 	}
 
-	parseRelease := distroObj.ParseRelease
-	release, err := parseRelease(release)
+	release, err := distroObj.parseRelease(release)
 	return release, err
 }
 
-func parseReleaseFedora(release string) (string, error) {
-	if strings.HasPrefix(release, "F") || strings.HasPrefix(release, "f") {
-		release = release[1:]
-	}
-
-	releaseN, err := strconv.Atoi(release)
-	if err != nil {
-		return "", err
-	}
-
-	if releaseN <= 0 {
-		return "", errors.New("release must be a positive integer")
-	}
-
-	return release, nil
-}
-
-func parseReleaseRHEL(release string) (string, error) {
-	if i := strings.IndexRune(release, '.'); i == -1 {
-		return "", errors.New("release must have a '.'")
-	}
-
-	releaseN, err := strconv.ParseFloat(release, 32)
-	if err != nil {
-		return "", err
-	}
-
-	if releaseN <= 0 {
-		return "", errors.New("release must be a positive number")
-	}
-
-	return release, nil
-}
-
 // PathExists wraps around os.Stat providing a nice interface for checking an existence of a path.
 func PathExists(path string) bool {
 	if _, err := os.Stat(path); !os.IsNotExist(err) {
@@ -732,14 +731,22 @@ func ResolveImageName(distroCLI, imageCLI, releaseCLI string) (string, string, e
 		}
 	}
 
-	if distro != distroDefault && releaseCLI == "" && !viper.IsSet("general.release") {
+	// Rolling distributions have no releases to choose from, so there's
+	// nothing to look for.
+	rolling := supportedDistros[distro].isRolling()
+
+	if distro != distroDefault && releaseCLI == "" && !viper.IsSet("general.release") && !rolling {
 		return "", "", fmt.Errorf("release not found for non-default distribution %s", distro)
 	}
 
 	if releaseCLI == "" {
-		release = releaseDefault
-		if viper.IsSet("general.release") {
-			release = viper.GetString("general.release")
+		if rolling {
+			release = releaseRolling
+		} else {
+			release = releaseDefault
+			if viper.IsSet("general.release") {
+				release = viper.GetString("general.release")
+			}
 		}
 	}
 
@@ -147,6 +147,62 @@ func TestParseRelease(t *testing.T) {
 			ok:           false,
 			errMsg:       "release must be a positive number",
 		},
+		{
+			name:         "Debian; 12; valid",
+			inputDistro:  "debian",
+			inputRelease: "12",
+			output:       "12",
+			ok:           true,
+		},
+		{
+			name:         "Debian; bookworm; valid",
+			inputDistro:  "debian",
+			inputRelease: "bookworm",
+			output:       "bookworm",
+			ok:           true,
+		},
+		{
+			name:         "Debian; Bookworm-1; invalid",
+			inputDistro:  "debian",
+			inputRelease: "Bookworm-1",
+			ok:           false,
+			errMsg:       "release must be a positive integer or a code name",
+		},
+		{
+			name:         "Ubuntu; 22.04; valid",
+			inputDistro:  "ubuntu",
+			inputRelease: "22.04",
+			output:       "22.04",
+			ok:           true,
+		},
+		{
+			name:         "Ubuntu; 22.13; invalid; not a month",
+			inputDistro:  "ubuntu",
+			inputRelease: "22.13",
+			ok:           false,
+			errMsg:       "release must be in the YY.MM format",
+		},
+		{
+			name:         "Ubuntu; 22; invalid; missing month",
+			inputDistro:  "ubuntu",
+			inputRelease: "22",
+			ok:           false,
+			errMsg:       "release must be in the YY.MM format",
+		},
+		{
+			name:         "Arch; latest; valid",
+			inputDistro:  "arch",
+			inputRelease: "latest",
+			output:       "latest",
+			ok:           true,
+		},
+		{
+			name:         "Arch; 2021.05.01; invalid; rolling",
+			inputDistro:  "arch",
+			inputRelease: "2021.05.01",
+			ok:           false,
+			errMsg:       "release must be 'latest' for rolling distributions",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -171,3 +227,80 @@ func TestParseRelease(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveImageName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		distro  string
+		release string
+		image   string
+		output  string
+		errMsg  string
+	}{
+		{
+			name:    "Debian",
+			distro:  "debian",
+			release: "12",
+			image:   "debian-toolbox:12",
+			output:  "12",
+		},
+		{
+			name:   "Debian without release",
+			distro: "debian",
+			errMsg: "release not found for non-default distribution debian",
+		},
+		{
+			name:   "Arch without release",
+			distro: "arch",
+			image:  "arch-toolbox:latest",
+			output: "latest",
+		},
+		{
+			name:    "Ubuntu",
+			distro:  "ubuntu",
+			release: "22.04",
+			image:   "ubuntu-toolbox:22.04",
+			output:  "22.04",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.distro == distroDefault {
+				t.Skipf("%s is the default distribution on this host", tc.distro)
+			}
+
+			image, release, err := ResolveImageName(tc.distro, "", tc.release)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.image, image)
+			assert.Equal(t, tc.output, release)
+		})
+	}
+}
+
+func TestGetFullyQualifiedImageFromDistros(t *testing.T) {
+	testCases := []struct {
+		image  string
+		output string
+	}{
+		{"arch-toolbox:latest", "quay.io/toolbx/arch-toolbox:latest"},
+		{"debian-toolbox:12", "quay.io/toolbx-images/debian-toolbox:12"},
+		{"fedora-toolbox:34", "registry.fedoraproject.org/fedora-toolbox:34"},
+		{"toolbox:8.4", "registry.access.redhat.com/ubi8/toolbox:8.4"},
+		{"ubuntu-toolbox:22.04", "quay.io/toolbx/ubuntu-toolbox:22.04"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			imageFull, err := GetFullyQualifiedImageFromDistros(tc.image, ImageReferenceGetTag(tc.image))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.output, imageFull)
+		})
+	}
+}
@@ -79,3 +79,12 @@ teardown() {
   assert_line --index 1 "If it was a private image, log in with: podman login foo.org"
   assert_line --index 2 "Use 'toolbox --verbose ...' for further details."
 }
+
+@test "create: Try to create a container with an invalid volume ('/opt/sdk')" {
+  run $TOOLBOX -y create --volume /opt/sdk
+
+  assert_failure
+  assert_line --index 0 "Error: invalid argument for '--volume'"
+  assert_line --index 1 "Volume must be SOURCE:DESTINATION[:OPTIONS]"
+  assert_line --index 2 "Run 'toolbox --help' for usage."
+}